@@ -1,16 +1,35 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"hash/crc32"
+	"html"
 	"log"
+	"math"
+	"math/rand"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/gofiber/fiber/v2"
@@ -70,6 +89,37 @@ func getNotificationChatIDs() []int64 {
 	return chatIDs
 }
 
+// getAdminUserIDs yönetici kullanıcı ID'lerini alır (virgülle ayrılmış)
+func getAdminUserIDs() []int64 {
+	return parseUserIDs(os.Getenv("ADMIN_USER_IDS"))
+}
+
+// parseUserIDs virgülle ayrılmış kullanıcı ID listesini ayrıştırır
+func parseUserIDs(value string) []int64 {
+	var userIDs []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var userID int64
+		if _, err := fmt.Sscanf(part, "%d", &userID); err == nil && userID != 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
+}
+
+// isAdmin kullanıcının yönetici olup olmadığını kontrol eder
+func isAdmin(userID int64) bool {
+	for _, id := range getAdminUserIDs() {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	bun.BaseModel `bun:"table:orders,alias:o"`
 
@@ -78,25 +128,55 @@ type Order struct {
 	Amount         float64     `bun:"amount,notnull"`
 	Currency       string      `bun:"currency,notnull"`
 	Items          []OrderItem `bun:"items,type:jsonb"`
-	UTMSource      string      `bun:"utm_source"`
-	UTMMedium      string      `bun:"utm_medium"`
-	UTMCampaign    string      `bun:"utm_campaign"`
+	UTMSource      string      `bun:"utm_source"`   // Raporlar adı orders_raw üzerinden utm_source_id ile okur
+	UTMMedium      string      `bun:"utm_medium"`   // Ham sütunlar kaldırılana kadar eski sürümler için yazılır
+	UTMCampaign    string      `bun:"utm_campaign"` // (bkz. rawUTMColumns)
 	UTMContent     string      `bun:"utm_content"`
 	UTMTerm        string      `bun:"utm_term"`
+	UTMSourceID    int64       `bun:"utm_source_id,nullzero"`
+	UTMMediumID    int64       `bun:"utm_medium_id,nullzero"`
+	UTMCampaignID  int64       `bun:"utm_campaign_id,nullzero"`
 	GadSource      string      `bun:"gad_source"`
 	GadCampaignID  string      `bun:"gad_campaignid"`
 	TrafficChannel string      `bun:"traffic_channel"`
 	EventTime      time.Time   `bun:"event_time,notnull"`
+	IsTest         bool        `bun:"is_test,notnull,default:false"`
 	CreatedAt      time.Time   `bun:"created_at,nullzero,notnull,default:current_timestamp"`
 }
 
 type OrderItem struct {
 	ItemID   string  `json:"item_id"`
 	ItemName string  `json:"item_name"`
+	Category string  `json:"item_category,omitempty"`
 	Quantity int     `json:"quantity"`
 	Price    float64 `json:"price"`
 }
 
+// OrderItemRow sipariş kalemlerini ayrı tabloda tutar (jsonb yerine indeksli join için)
+type OrderItemRow struct {
+	bun.BaseModel `bun:"table:order_items,alias:oi"`
+
+	ID       int64   `bun:"id,pk,autoincrement"`
+	OrderPK  int64   `bun:"order_pk,notnull"`
+	ItemID   string  `bun:"item_id"`
+	ItemName string  `bun:"item_name,notnull"`
+	Category string  `bun:"category"`
+	Quantity int     `bun:"quantity,notnull"`
+	Price    float64 `bun:"price,notnull"`
+}
+
+// CampaignTag kampanyaya açıkça atanmış sınıflandırma etiketlerini tutar
+type CampaignTag struct {
+	bun.BaseModel `bun:"table:campaign_tags,alias:ct"`
+
+	Campaign  string    `bun:"campaign,pk"`
+	Country   string    `bun:"country"`
+	Product   string    `bun:"product"`
+	Objective string    `bun:"objective"`
+	Owner     string    `bun:"owner"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
 type ThrowDataRequest struct {
 	OrderID        string      `json:"order_id"`
 	Amount         float64     `json:"amount"`
@@ -111,6 +191,8 @@ type ThrowDataRequest struct {
 	GadCampaignID  string      `json:"gad_campaignid"`
 	TrafficChannel string      `json:"traffic_channel"`
 	EventTime      time.Time   `json:"event_time"`
+	IsTest         bool        `json:"is_test"`      // Yük testi vb. sentetik sipariş (bildirim gönderilmez)
+	CallbackURL    string      `json:"callback_url"` // Kayıt sonrası imzalı onayın gönderileceği adres (opsiyonel)
 }
 
 func initDatabase() error {
@@ -130,12 +212,67 @@ func initDatabase() error {
 
 	log.Println("PostgreSQL veritabanına bağlandı")
 
+	// UTM boyut tablolarını oluştur (orders tablosu bunlara referans verir)
+	for _, table := range utmDimensionTables {
+		query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id BIGSERIAL PRIMARY KEY, name VARCHAR(255) NOT NULL UNIQUE)", table)
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%s tablosu oluşturulamadı: %w", table, err)
+		}
+	}
+
 	// Tabloları oluştur
-	_, err := db.NewCreateTable().Model((*Order)(nil)).IfNotExists().Exec(ctx)
+	_, err := db.NewCreateTable().
+		Model((*Order)(nil)).
+		IfNotExists().
+		ForeignKey(`("utm_source_id") REFERENCES "utm_sources" ("id")`).
+		ForeignKey(`("utm_medium_id") REFERENCES "utm_mediums" ("id")`).
+		ForeignKey(`("utm_campaign_id") REFERENCES "utm_campaigns" ("id")`).
+		Exec(ctx)
 	if err != nil {
 		return fmt.Errorf("tablo oluşturulamadı: %w", err)
 	}
 
+	if _, err := db.NewCreateTable().Model((*UTMPreset)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("utm_presets tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*UsageCounter)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("usage_counters tablosu oluşturulamadı: %w", err)
+	}
+
+	_, err = db.NewCreateTable().
+		Model((*OrderItemRow)(nil)).
+		IfNotExists().
+		ForeignKey(`("order_pk") REFERENCES "orders" ("id") ON DELETE CASCADE`).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("order_items tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*CampaignTag)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("campaign_tags tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*NotificationChatSetting)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("notification_chat_settings tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*GoogleAdsCampaign)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("google_ads_campaigns tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*DataScope)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("data_scopes tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*CampaignMilestone)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("campaign_milestones tablosu oluşturulamadı: %w", err)
+	}
+
+	if _, err := db.NewCreateTable().Model((*SchemaMigration)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("schema_migrations tablosu oluşturulamadı: %w", err)
+	}
+
 	// Yeni sütunları ekle (migration)
 	migrations := []string{
 		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS utm_content VARCHAR(255)",
@@ -143,6 +280,20 @@ func initDatabase() error {
 		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS gad_source VARCHAR(255)",
 		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS gad_campaignid VARCHAR(255)",
 		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS traffic_channel VARCHAR(255)",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS is_test BOOLEAN NOT NULL DEFAULT FALSE",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS utm_source_id BIGINT REFERENCES utm_sources(id)",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS utm_medium_id BIGINT REFERENCES utm_mediums(id)",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS utm_campaign_id BIGINT REFERENCES utm_campaigns(id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_utm_source_id ON orders (utm_source_id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_utm_medium_id ON orders (utm_medium_id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_utm_campaign_id ON orders (utm_campaign_id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_event_time ON orders (event_time)",
+		"CREATE INDEX IF NOT EXISTS idx_order_items_order_pk ON order_items (order_pk)",
+		"CREATE INDEX IF NOT EXISTS idx_order_items_item_name ON order_items (item_name)",
+		"CREATE INDEX IF NOT EXISTS idx_order_items_category ON order_items (category)",
+		// /kalem aramaları ILIKE '%ad%' kullanır; btree bunu karşılamaz, trigram GIN indeksi gerekir
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_order_items_item_name_trgm ON order_items USING gin (item_name gin_trgm_ops)",
 	}
 
 	for _, migration := range migrations {
@@ -151,10 +302,158 @@ func initDatabase() error {
 		}
 	}
 
+	// Eski siparişlerin jsonb kalemlerini order_items tablosuna taşı (bir kez)
+	if err := runOnceMigration(ctx, "order_items_backfill", backfillOrderItems); err != nil {
+		log.Printf("Migration uyarı (order_items_backfill): %v", err)
+	}
+
+	// Ham UTM sütunları duruyorsa ID'siz kayıtları boyut tablolarına taşı. Her açılışta çalışır:
+	// dağıtım sırasında eski sürümün yazdığı kayıtlar da bir sonraki açılışta ID alır
+	hasRaw, err := hasRawUTMColumns(ctx, db)
+	if err != nil {
+		return fmt.Errorf("orders sütunları okunamadı: %w", err)
+	}
+	rawUTMColumns.Store(hasRaw)
+	if hasRaw {
+		if err := backfillUTMDimensionIDs(ctx, db); err != nil {
+			log.Printf("Migration uyarı (UTM boyut ID'leri): %v", err)
+		}
+	}
+
+	if err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return createOrdersRawView(ctx, tx, hasRaw)
+	}); err != nil {
+		return fmt.Errorf("orders_raw görünümü oluşturulamadı: %w", err)
+	}
+
 	log.Println("Veritabanı tabloları hazır")
 	return nil
 }
 
+// SchemaMigration bir kez çalışması gereken veri migration'larının kaydını tutar
+type SchemaMigration struct {
+	bun.BaseModel `bun:"table:schema_migrations,alias:sm"`
+
+	Name      string    `bun:"name,pk"`
+	AppliedAt time.Time `bun:"applied_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// runOnceMigration migration'ı kaydıyla aynı transaction içinde bir kez çalıştırır; kayıt varsa atlar
+func runOnceMigration(ctx context.Context, name string, fn func(ctx context.Context, tx bun.Tx) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewInsert().Model(&SchemaMigration{Name: name}).On("CONFLICT (name) DO NOTHING").Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		log.Printf("Migration çalıştırılıyor: %s", name)
+		return fn(ctx, tx)
+	})
+}
+
+// backfillOrderItems eski siparişlerin jsonb kalemlerini order_items tablosuna taşır
+// Sayısal olmayan adet/fiyat değerleri sorguyu düşürmek yerine 0 yazılır, ondalıklı adetler yuvarlanır
+func backfillOrderItems(ctx context.Context, tx bun.Tx) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO order_items (order_pk, item_id, item_name, category, quantity, price)
+		SELECT o.id, item->>'item_id', COALESCE(item->>'item_name', ''), item->>'item_category',
+			COALESCE(CASE
+				WHEN jsonb_typeof(item->'quantity') = 'number'
+					OR item->>'quantity' ~ '^[0-9]+([.][0-9]+){0,1}$' THEN round((item->>'quantity')::numeric)::int
+			END, 0),
+			COALESCE(CASE
+				WHEN jsonb_typeof(item->'price') = 'number'
+					OR item->>'price' ~ '^[0-9]+([.][0-9]+){0,1}$' THEN (item->>'price')::numeric
+			END, 0)
+		FROM orders o, jsonb_array_elements(o.items) AS item
+		WHERE jsonb_typeof(o.items) = 'array'
+		AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_pk = o.id)`)
+	return err
+}
+
+// rawUTMColumns orders tablosunda eski ham utm_source/utm_medium/utm_campaign sütunlarının durup durmadığını tutar.
+// Sütunlar -drop-raw-utm-columns ile açıkça kaldırılana kadar yeni kayıtlara da yazılır; böylece dağıtım
+// sırasında hâlâ çalışan eski sürümler bu sütunları okuyup yazmaya devam edebilir.
+var rawUTMColumns atomic.Bool
+
+// hasRawUTMColumns orders tablosunda ham UTM sütunlarının olup olmadığını döner
+func hasRawUTMColumns(ctx context.Context, idb bun.IDB) (bool, error) {
+	var exists bool
+	err := idb.NewRaw(`SELECT EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'orders' AND column_name = 'utm_source'
+	)`).Scan(ctx, &exists)
+	return exists, err
+}
+
+// backfillUTMDimensionIDs ham UTM değeri olup boyut ID'si olmayan siparişlere ID yazar
+func backfillUTMDimensionIDs(ctx context.Context, idb bun.IDB) error {
+	for _, column := range []string{"utm_source", "utm_medium", "utm_campaign"} {
+		table := utmDimensionTables[column]
+		queries := []string{
+			fmt.Sprintf("INSERT INTO %[2]s (name) SELECT DISTINCT %[1]s FROM orders WHERE %[1]s IS NOT NULL AND %[1]s != '' AND %[1]s_id IS NULL ON CONFLICT (name) DO NOTHING", column, table),
+			fmt.Sprintf("UPDATE orders o SET %[1]s_id = d.id FROM %[2]s d WHERE o.%[1]s = d.name AND o.%[1]s_id IS NULL", column, table),
+		}
+		for _, query := range queries {
+			if _, err := idb.NewRaw(query).Exec(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createOrdersRawView boyut tablolarından çözülmüş UTM adlarıyla sipariş görünümünü oluşturur (rapor sorguları bunu okur)
+// Ham sütunlar duruyorsa henüz ID almamış (eski sürümün yazdığı) kayıtların adı ham sütundan okunur
+func createOrdersRawView(ctx context.Context, tx bun.Tx, hasRaw bool) error {
+	names := "s.name AS utm_source, m.name AS utm_medium, c.name AS utm_campaign"
+	if hasRaw {
+		names = "COALESCE(s.name, o.utm_source) AS utm_source, COALESCE(m.name, o.utm_medium) AS utm_medium, COALESCE(c.name, o.utm_campaign) AS utm_campaign"
+	}
+	// Sütun tipleri iki tanım arasında değişebildiğinden CREATE OR REPLACE yerine aynı transaction'da yeniden oluşturulur
+	queries := []string{
+		"DROP VIEW IF EXISTS orders_raw",
+		`CREATE VIEW orders_raw AS
+		SELECT o.id, o.order_id, o.amount, o.currency, o.items,
+			` + names + `,
+			o.utm_content, o.utm_term, o.gad_source, o.gad_campaignid, o.traffic_channel,
+			o.event_time, o.is_test, o.created_at,
+			o.utm_source_id, o.utm_medium_id, o.utm_campaign_id
+		FROM orders o
+		LEFT JOIN utm_sources s ON s.id = o.utm_source_id
+		LEFT JOIN utm_mediums m ON m.id = o.utm_medium_id
+		LEFT JOIN utm_campaigns c ON c.id = o.utm_campaign_id`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropRawUTMColumns ham UTM sütunlarını kaldırır (-drop-raw-utm-columns). Geri alınamaz; sadece tüm örnekler
+// boyut ID'lerini okuyan sürüme geçtikten sonra çalıştırılmalıdır. Son ID'siz kayıtlar önce taşınır.
+func dropRawUTMColumns(ctx context.Context) error {
+	return runOnceMigration(ctx, "drop_raw_utm_columns", func(ctx context.Context, tx bun.Tx) error {
+		hasRaw, err := hasRawUTMColumns(ctx, tx)
+		if err != nil || !hasRaw {
+			return err
+		}
+		if err := backfillUTMDimensionIDs(ctx, tx); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DROP VIEW IF EXISTS orders_raw"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "ALTER TABLE orders DROP COLUMN utm_source, DROP COLUMN utm_medium, DROP COLUMN utm_campaign"); err != nil {
+			return err
+		}
+		return createOrdersRawView(ctx, tx, false)
+	})
+}
+
 // startFiberServer Fiber HTTP server'ı başlatır
 func startFiberServer() {
 	app := fiber.New(fiber.Config{
@@ -192,12 +491,25 @@ func startFiberServer() {
 
 	// Health check endpoint
 	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "ok"})
+		var lastIngest interface{}
+		if ts := lastIngestAt.Load(); ts > 0 {
+			lastIngest = time.Unix(ts, 0).UTC()
+		}
+		return c.JSON(fiber.Map{"status": "ok", "last_ingest_at": lastIngest, "ingest_failures": consecutiveIngestFailures.Load()})
 	})
 
 	// Throw data endpoint
 	app.Post("/throw-data", handleThrowData)
 
+	// CSV rapor endpoint'leri (Google Sheets IMPORTDATA için)
+	reports := app.Group("/reports", requireReportsToken)
+	reports.Get("/sources.csv", handleSourcesCSV)
+	reports.Get("/campaigns.csv", handleCampaignsCSV)
+	reports.Get("/daily.csv", handleDailyCSV)
+
+	// Google Ads kampanya listesi senkronizasyonu (Ads Script vb. tarafından gönderilir)
+	app.Post("/google-ads/campaigns", requireGoogleAdsSyncToken, handleGoogleAdsCampaignSync)
+
 	port := getEnv("API_PORT", "3061")
 	log.Printf("Fiber API sunucusu başlatılıyor: :%s", port)
 
@@ -217,7 +529,21 @@ func handleThrowData(c *fiber.Ctx) error {
 		})
 	}
 
-	log.Printf("Yeni sipariş alındı: %s, Tutar: %.2f %s", req.OrderID, req.Amount, req.Currency)
+	log.Printf("Yeni sipariş alındı: %s, Tutar: %s", req.OrderID, formatMoney(req.Amount, req.Currency))
+
+	// is_test bildirimleri ve kampanya hedeflerini atladığından sadece yük testi token'ıyla kabul edilir
+	if req.IsTest && !isLoadTestRequest(c) {
+		log.Printf("is_test reddedildi (order_id=%s): geçersiz yük testi token'ı", req.OrderID)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "is_test sadece yük testi token'ı ile gönderilebilir",
+		})
+	}
+
+	// Tutarlar para biriminin en küçük birimine yuvarlanır (JSON float artıkları JPY/KWD'de yanlış görünmesin)
+	req.Amount = roundToCurrency(req.Amount, req.Currency)
+	for i := range req.Items {
+		req.Items[i].Price = roundToCurrency(req.Items[i].Price, req.Currency)
+	}
 
 	// Veritabanına kaydet
 	order := &Order{
@@ -234,47 +560,269 @@ func handleThrowData(c *fiber.Ctx) error {
 		GadCampaignID:  req.GadCampaignID,
 		TrafficChannel: req.TrafficChannel,
 		EventTime:      req.EventTime,
+		IsTest:         req.IsTest,
 	}
 
 	ctx := context.Background()
-	_, err := db.NewInsert().Model(order).Exec(ctx)
-	if err != nil {
+	if err := saveOrder(ctx, order); err != nil {
 		log.Printf("Veritabanı kayıt hatası: %v", err)
+		recordIngestResult(err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Veritabanı hatası",
 		})
 	}
+	recordIngestResult(nil)
+	addToTodayAggregate(order)
+
+	// Test siparişleri için bildirim gönderilmez
+	var sent, failed int
+	if !req.IsTest {
+		sent, failed = dispatchOrderNotifications(ctx, &req, order)
+		// Hedef kontrolü veritabanına gider, yanıtı geciktirmemesi için arka planda yapılır
+		go checkCampaignMilestones(ctx, order)
+	}
+
+	response := fiber.Map{
+		"success": true,
+		"message": "Veri başarıyla kaydedildi ve bildirim gönderildi",
+	}
+	if req.IsTest {
+		response["message"] = "Test verisi kaydedildi"
+	}
+
+	// İsteğe bağlı imzalı onay callback'i (sipariş kaydedildi + bildirim durumu)
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			log.Printf("Callback reddedildi (order_id=%s): %v", req.OrderID, err)
+			response["callback_error"] = err.Error()
+		} else {
+			go sendIngestCallback(req.CallbackURL, ingestConfirmation{
+				OrderID:           req.OrderID,
+				Stored:            true,
+				IsTest:            req.IsTest,
+				NotificationsSent: sent,
+				NotificationsFail: failed,
+				ConfirmedAt:       time.Now().UTC(),
+			})
+		}
+	}
+
+	return c.JSON(response)
+}
 
+// dispatchOrderNotifications siparişi bildirim hedeflerine gönderir, başarılı/başarısız gönderim sayısını döner
+func dispatchOrderNotifications(ctx context.Context, req *ThrowDataRequest, order *Order) (sent, failed int) {
 	// Telegram'a bildirim gönder (tüm hedeflere)
 	chatIDs := getNotificationChatIDs()
-	if len(chatIDs) > 0 && globalBot != nil {
-		// Yüksek bağış kontrolü (24999 TL ve üzeri)
-		isHighDonation := req.Amount >= 24999
+	if len(chatIDs) == 0 || globalBot == nil {
+		return 0, 0
+	}
 
-		var message string
-		if isHighDonation {
-			message = formatHighDonationMessage(&req)
+	// Yüksek bağış kontrolü (24999 TL ve üzeri)
+	isHighDonation := req.Amount >= 24999
+
+	// Şablon başına mesaj bir kez oluşturulur (kanallar: genel, gruplar: tam)
+	messages := make(map[string]string)
+	for _, chatID := range chatIDs {
+		// Kapsamlı sohbetler sadece kendi kampanya/kaynaklarının bağışlarını alır
+		if !getChatScope(ctx, chatID).matches(order) {
+			continue
+		}
+
+		template := resolveNotificationTemplate(ctx, globalBot, chatID)
+		message, ok := messages[template]
+		if !ok {
+			message = formatNotification(req, template, isHighDonation)
+			messages[template] = message
+		}
+
+		msg := tgbotapi.NewMessage(chatID, message)
+		msg.ParseMode = "HTML"
+		if _, err := globalBot.Send(msg); err != nil {
+			log.Printf("Telegram mesaj gönderme hatası (chat_id=%d): %v", chatID, err)
+			failed++
 		} else {
-			message = formatOrderMessage(&req)
+			log.Printf("Telegram bildirimi gönderildi: chat_id=%d", chatID)
+			sent++
 		}
+	}
+	return sent, failed
+}
 
-		for _, chatID := range chatIDs {
-			msg := tgbotapi.NewMessage(chatID, message)
-			msg.ParseMode = "HTML"
-			if _, err := globalBot.Send(msg); err != nil {
-				log.Printf("Telegram mesaj gönderme hatası (chat_id=%d): %v", chatID, err)
-			} else {
-				log.Printf("Telegram bildirimi gönderildi: chat_id=%d", chatID)
-			}
+// currencyDecimals ISO-4217'ye göre 2'den farklı ondalık basamak kullanan para birimleri.
+// Listede olmayan para birimleri 2 basamaklı kabul edilir.
+var currencyDecimals = map[string]int{
+	// Kuruşsuz para birimleri
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	// 3 basamaklı (fils/baisa) para birimleri
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	// Hesap birimleri
+	"CLF": 4, "UYW": 4,
+}
+
+// currencyDecimalPlaces para biriminin ondalık basamak sayısını döner
+func currencyDecimalPlaces(currency string) int {
+	if decimals, ok := currencyDecimals[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// roundToCurrency tutarı para biriminin en küçük birimine yuvarlar (ör. 1500.0000001 JPY -> 1500)
+func roundToCurrency(amount float64, currency string) float64 {
+	factor := math.Pow(10, float64(currencyDecimalPlaces(currency)))
+	return math.Round(amount*factor) / factor
+}
+
+// formatAmount tutarı para biriminin ondalık basamağıyla yazar (para birimi eklenmez)
+func formatAmount(amount float64, currency string) string {
+	return strconv.FormatFloat(amount, 'f', currencyDecimalPlaces(currency), 64)
+}
+
+// formatMoney tutarı para birimiyle birlikte yazar: "1500 JPY", "12.500 KWD", "250.00 TRY"
+func formatMoney(amount float64, currency string) string {
+	return formatAmount(amount, currency) + " " + currency
+}
+
+// currencyGroupOrder para birimi bazında gruplanmış toplam satırlarını en çok bağış alan para birimi
+// önce gelecek şekilde sıralar (moneyTotals ile aynı sıra); satırlar para birimi içinde ayrıca sıralanır
+const currencyGroupOrder = "SUM(count) OVER (PARTITION BY currency) DESC, currency"
+
+// currencyTotal bir para birimindeki toplam tutar ve bağış sayısı
+type currencyTotal struct {
+	Currency string  `bun:"currency"`
+	Total    float64 `bun:"total"`
+	Count    int     `bun:"count"`
+}
+
+// moneyTotals para birimi bazında toplamlar; farklı para birimleri birbirine eklenmez
+type moneyTotals []currencyTotal
+
+// add para biriminin toplamına tutar ve bağış sayısı ekler
+func (t *moneyTotals) add(currency string, total float64, count int) {
+	for i := range *t {
+		if (*t)[i].Currency == currency {
+			(*t)[i].Total += total
+			(*t)[i].Count += count
+			return
 		}
 	}
+	*t = append(*t, currencyTotal{Currency: currency, Total: total, Count: count})
+}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Veri başarıyla kaydedildi ve bildirim gönderildi",
+// sort para birimlerini bağış sayısına göre azalan, eşitse ada göre sıralar
+func (t moneyTotals) sort() {
+	sort.Slice(t, func(i, j int) bool {
+		if t[i].Count != t[j].Count {
+			return t[i].Count > t[j].Count
+		}
+		return t[i].Currency < t[j].Currency
 	})
 }
 
+// rank para biriminin sıralı listedeki yerini döner (satırları para birimine göre gruplamak için)
+func (t moneyTotals) rank(currency string) int {
+	for i, ct := range t {
+		if ct.Currency == currency {
+			return i
+		}
+	}
+	return len(t)
+}
+
+// count tüm para birimlerindeki toplam bağış sayısını döner
+func (t moneyTotals) count() int {
+	var count int
+	for _, ct := range t {
+		count += ct.Count
+	}
+	return count
+}
+
+// share tutarın kendi para birimindeki toplam içindeki yüzdesini döner
+func (t moneyTotals) share(currency string, amount float64) float64 {
+	if i := t.rank(currency); i < len(t) && t[i].Total != 0 {
+		return amount / t[i].Total * 100
+	}
+	return 0
+}
+
+// String toplamları para birimleriyle yazar: "250.00 TRY | 1500 JPY" (toplam yoksa "—")
+func (t moneyTotals) String() string {
+	if len(t) == 0 {
+		return "—"
+	}
+	parts := make([]string, 0, len(t))
+	for _, ct := range t {
+		parts = append(parts, formatMoney(ct.Total, ct.Currency))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// averages para birimi bazında ortalama bağışı yazar: "125.00 TRY | 750 JPY"
+func (t moneyTotals) averages() string {
+	parts := make([]string, 0, len(t))
+	for _, ct := range t {
+		if ct.Count > 0 {
+			parts = append(parts, formatMoney(ct.Total/float64(ct.Count), ct.Currency))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// orderTotals siparişlerin para birimi bazında toplamlarını döner
+func orderTotals(orders []Order) moneyTotals {
+	var totals moneyTotals
+	for _, o := range orders {
+		totals.add(o.Currency, o.Amount, 1)
+	}
+	totals.sort()
+	return totals
+}
+
+// currencySections para birimine göre gruplanmış satırlarda para birimi içindeki sırayı tutar;
+// birden fazla para birimi varsa her para biriminin ilk satırından önce başlık yazar
+type currencySections struct {
+	multiple bool
+	currency string
+	rank     int
+}
+
+// next satırın para birimi içindeki sırasını (0'dan) döner
+func (s *currencySections) next(sb *strings.Builder, currency string) int {
+	if s.rank == 0 || currency != s.currency {
+		s.currency, s.rank = currency, 0
+		if s.multiple {
+			sb.WriteString(htmlf("💱 <b>%s</b>\n\n", currency))
+		}
+	}
+	s.rank++
+	return s.rank - 1
+}
+
+// htmlText zaten HTML olarak hazırlanmış, htmlf tarafından kaçışlanmayacak metin
+type htmlText string
+
+// htmlf HTML modundaki mesajlar için fmt.Sprintf karşılığı. Format metnindeki etiketler
+// korunur; metin argümanları (kalem adı, kampanya, URL vb.) HTML kaçışlanarak eklenir.
+func htmlf(format string, args ...interface{}) string {
+	safe := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case htmlText:
+			safe[i] = string(v)
+		case string:
+			safe[i] = html.EscapeString(v)
+		case error:
+			safe[i] = html.EscapeString(v.Error())
+		default:
+			safe[i] = arg
+		}
+	}
+	return fmt.Sprintf(format, safe...)
+}
+
 // formatOrderMessage siparişi okunabilir mesaja dönüştürür (HTML format)
 func formatOrderMessage(req *ThrowDataRequest) string {
 	var sb strings.Builder
@@ -283,14 +831,14 @@ func formatOrderMessage(req *ThrowDataRequest) string {
 	turkeyTime := req.EventTime.Add(3 * time.Hour)
 
 	sb.WriteString("🛒 <b>Yeni Bağış Bildirimi</b>\n\n")
-	sb.WriteString(fmt.Sprintf("📋 <b>Sipariş ID:</b> <code>%s</code>\n", req.OrderID))
-	sb.WriteString(fmt.Sprintf("💰 <b>Tutar:</b> %.2f %s\n", req.Amount, req.Currency))
-	sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s\n\n", turkeyTime.Format("02.01.2006 15:04:05")))
+	sb.WriteString(htmlf("📋 <b>Sipariş ID:</b> <code>%s</code>\n", req.OrderID))
+	sb.WriteString(htmlf("💰 <b>Tutar:</b> %s\n", formatMoney(req.Amount, req.Currency)))
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s\n\n", turkeyTime.Format("02.01.2006 15:04:05")))
 
 	if len(req.Items) > 0 {
 		sb.WriteString("📦 <b>Bağış Kalemleri:</b>\n")
 		for _, item := range req.Items {
-			sb.WriteString(fmt.Sprintf("  • %s (x%d) - %.2f %s\n", item.ItemName, item.Quantity, item.Price, req.Currency))
+			sb.WriteString(htmlf("  • %s (x%d) - %s\n", item.ItemName, item.Quantity, formatMoney(item.Price, req.Currency)))
 		}
 		sb.WriteString("\n")
 	}
@@ -300,19 +848,19 @@ func formatOrderMessage(req *ThrowDataRequest) string {
 	if hasUTM {
 		sb.WriteString("📊 <b>UTM Bilgileri:</b>\n")
 		if req.UTMSource != "" {
-			sb.WriteString(fmt.Sprintf("  • Kaynak: %s\n", req.UTMSource))
+			sb.WriteString(htmlf("  • Kaynak: %s\n", req.UTMSource))
 		}
 		if req.UTMMedium != "" {
-			sb.WriteString(fmt.Sprintf("  • Ortam: %s\n", req.UTMMedium))
+			sb.WriteString(htmlf("  • Ortam: %s\n", req.UTMMedium))
 		}
 		if req.UTMCampaign != "" {
-			sb.WriteString(fmt.Sprintf("  • Kampanya: %s\n", req.UTMCampaign))
+			sb.WriteString(htmlf("  • Kampanya: %s\n", req.UTMCampaign))
 		}
 		if req.UTMContent != "" {
-			sb.WriteString(fmt.Sprintf("  • İçerik: %s\n", req.UTMContent))
+			sb.WriteString(htmlf("  • İçerik: %s\n", req.UTMContent))
 		}
 		if req.UTMTerm != "" {
-			sb.WriteString(fmt.Sprintf("  • Terim: %s\n", req.UTMTerm))
+			sb.WriteString(htmlf("  • Terim: %s\n", req.UTMTerm))
 		}
 		sb.WriteString("\n")
 	}
@@ -322,17 +870,17 @@ func formatOrderMessage(req *ThrowDataRequest) string {
 	if hasGoogle {
 		sb.WriteString("🔍 <b>Google Ads Bilgileri:</b>\n")
 		if req.GadSource != "" {
-			sb.WriteString(fmt.Sprintf("  • gad_source: %s\n", req.GadSource))
+			sb.WriteString(htmlf("  • gad_source: %s\n", req.GadSource))
 		}
 		if req.GadCampaignID != "" {
-			sb.WriteString(fmt.Sprintf("  • gad_campaignid: %s\n", req.GadCampaignID))
+			sb.WriteString(htmlf("  • gad_campaignid: %s\n", req.GadCampaignID))
 		}
 		sb.WriteString("\n")
 	}
 
 	// Trafik Kanalı
 	if req.TrafficChannel != "" {
-		sb.WriteString(fmt.Sprintf("📡 <b>Trafik Kanalı:</b> %s\n", req.TrafficChannel))
+		sb.WriteString(htmlf("📡 <b>Trafik Kanalı:</b> %s\n", req.TrafficChannel))
 	}
 
 	return sb.String()
@@ -349,15 +897,15 @@ func formatHighDonationMessage(req *ThrowDataRequest) string {
 	sb.WriteString("💎💎💎 <b>YÜKSEK BAĞIŞ!</b> 💎💎💎\n")
 	sb.WriteString("🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉\n\n")
 
-	sb.WriteString(fmt.Sprintf("🚀 <b>Tutar:</b> <code>%.2f %s</code> 🚀\n\n", req.Amount, req.Currency))
+	sb.WriteString(htmlf("🚀 <b>Tutar:</b> <code>%s</code> 🚀\n\n", formatMoney(req.Amount, req.Currency)))
 
-	sb.WriteString(fmt.Sprintf("📋 <b>Sipariş ID:</b> <code>%s</code>\n", req.OrderID))
-	sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s\n\n", turkeyTime.Format("02.01.2006 15:04:05")))
+	sb.WriteString(htmlf("📋 <b>Sipariş ID:</b> <code>%s</code>\n", req.OrderID))
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s\n\n", turkeyTime.Format("02.01.2006 15:04:05")))
 
 	if len(req.Items) > 0 {
 		sb.WriteString("📦 <b>Bağış Kalemleri:</b>\n")
 		for _, item := range req.Items {
-			sb.WriteString(fmt.Sprintf("  • %s (x%d) - %.2f %s\n", item.ItemName, item.Quantity, item.Price, req.Currency))
+			sb.WriteString(htmlf("  • %s (x%d) - %s\n", item.ItemName, item.Quantity, formatMoney(item.Price, req.Currency)))
 		}
 		sb.WriteString("\n")
 	}
@@ -367,13 +915,13 @@ func formatHighDonationMessage(req *ThrowDataRequest) string {
 	if hasUTM {
 		sb.WriteString("📊 <b>UTM Bilgileri:</b>\n")
 		if req.UTMSource != "" {
-			sb.WriteString(fmt.Sprintf("  • Kaynak: %s\n", req.UTMSource))
+			sb.WriteString(htmlf("  • Kaynak: %s\n", req.UTMSource))
 		}
 		if req.UTMMedium != "" {
-			sb.WriteString(fmt.Sprintf("  • Ortam: %s\n", req.UTMMedium))
+			sb.WriteString(htmlf("  • Ortam: %s\n", req.UTMMedium))
 		}
 		if req.UTMCampaign != "" {
-			sb.WriteString(fmt.Sprintf("  • Kampanya: %s\n", req.UTMCampaign))
+			sb.WriteString(htmlf("  • Kampanya: %s\n", req.UTMCampaign))
 		}
 		sb.WriteString("\n")
 	}
@@ -383,10 +931,10 @@ func formatHighDonationMessage(req *ThrowDataRequest) string {
 	if hasGoogle {
 		sb.WriteString("🔍 <b>Google Ads Bilgileri:</b>\n")
 		if req.GadSource != "" {
-			sb.WriteString(fmt.Sprintf("  • gad_source: %s\n", req.GadSource))
+			sb.WriteString(htmlf("  • gad_source: %s\n", req.GadSource))
 		}
 		if req.GadCampaignID != "" {
-			sb.WriteString(fmt.Sprintf("  • gad_campaignid: %s\n", req.GadCampaignID))
+			sb.WriteString(htmlf("  • gad_campaignid: %s\n", req.GadCampaignID))
 		}
 		sb.WriteString("\n")
 	}
@@ -406,6 +954,7 @@ type UserSession struct {
 	Campaign  string // utm_campaign
 	Content   string // utm_content
 	Term      string // utm_term (opsiyonel)
+	Preset    string // Hazır ayar adı (deep link ile başlatıldıysa)
 }
 
 // sessions tüm kullanıcı oturumlarını tutar
@@ -419,13 +968,49 @@ var utmSourceOptions = []string{"meta", "google", "tiktok", "linkedin", "sms", "
 var utmMediumOptions = []string{"paid_social", "cpc", "display", "paid_search", "sms", "email", "organic_social"}
 
 func main() {
+	loadTest := flag.Bool("loadtest", false, "Bot yerine /throw-data yük testini çalıştır")
+	loadTestTarget := flag.String("target", "http://localhost:3061", "Yük testi hedef API adresi (staging)")
+	loadTestRPS := flag.Int("rps", 20, "Yük testinde saniyedeki istek sayısı")
+	loadTestDuration := flag.Duration("duration", 30*time.Second, "Yük testi süresi")
+	modeName := flag.String("mode", getEnv("RUN_MODE", "all"), "Çalışma modu: bot, api, worker veya all")
+	dropRawUTM := flag.Bool("drop-raw-utm-columns", false, "orders tablosundaki ham UTM sütunlarını kaldır ve çık (geri alınamaz)")
+	flag.Parse()
+
+	if *loadTest {
+		if err := runLoadTest(*loadTestTarget, *loadTestRPS, *loadTestDuration); err != nil {
+			log.Fatalf("Yük testi başarısız: %v", err)
+		}
+		return
+	}
+
+	if *dropRawUTM {
+		if err := initDatabase(); err != nil {
+			log.Fatalf("Veritabanı başlatılamadı: %v", err)
+		}
+		if err := dropRawUTMColumns(context.Background()); err != nil {
+			log.Fatalf("Ham UTM sütunları kaldırılamadı: %v", err)
+		}
+		log.Println("Ham UTM sütunları kaldırıldı")
+		return
+	}
+
+	mode, err := parseRunMode(*modeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Çalışma modu: %s", *modeName)
+
 	// Veritabanını başlat
-	if err := initDatabase(); err != nil {
-		log.Printf("UYARI: Veritabanı başlatılamadı: %v", err)
+	dbErr := initDatabase()
+	if dbErr != nil {
+		log.Printf("UYARI: Veritabanı başlatılamadı: %v", dbErr)
 		log.Println("Bot veritabanı olmadan çalışmaya devam edecek")
 	}
 
-	// Bot'u oluştur
+	// all modu tek örnek olarak çalışır; veritabanı yokken de kilit beklenmez (aksi halde bot hiç polling yapmaz)
+	leaderElection = dbErr == nil && !mode.all()
+
+	// Bot'u oluştur (api modunda da bildirim göndermek için gerekir, güncellemeler sadece bot modunda alınır)
 	bot, err := tgbotapi.NewBotAPI(getBotToken())
 	if err != nil {
 		log.Panic(err)
@@ -438,30 +1023,80 @@ func main() {
 	log.Printf("Bot başlatıldı: @%s", bot.Self.UserName)
 
 	// Fiber sunucusunu ayrı goroutine'de başlat
-	go startFiberServer()
+	if mode.API {
+		ingestInProcess = true
+		go startFiberServer()
+	}
 
-	// Update config
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	// Periyodik işleri başlat (heartbeat vb.)
+	startScheduler(getScheduledJobs(mode))
 
-	updates := bot.GetUpdatesChan(u)
+	if !mode.Bot {
+		// api/worker modunda Telegram güncellemeleri alınmaz, sadece arka plan işleri çalışır
+		select {}
+	}
 
-	for update := range updates {
-		log.Printf("Update alındı: %+v", update)
+	// Aynı token ile tek poller çalışmalı, diğer bot örnekleri yedekte bekler; liderlik düşerse yeniden beklenir
+	for {
+		leaderCtx := acquireLeadership("telegram-poller")
+		runUpdatePoller(leaderCtx, bot)
+	}
+}
 
-		// Callback query (inline button tıklaması)
-		if update.CallbackQuery != nil {
-			log.Printf("Callback query: user=%d, data=%s", update.CallbackQuery.From.ID, update.CallbackQuery.Data)
-			handleCallback(bot, update.CallbackQuery)
+// runUpdatePoller Telegram güncellemelerini ctx iptal edilene kadar alır ve işler (aynı bot token'ı ile tek bir süreçte çalışmalı)
+// Kütüphanenin GetUpdatesChan'i durdurulduktan sonra yeniden başlatılamadığından long polling elle yapılır
+func runUpdatePoller(ctx context.Context, bot *tgbotapi.BotAPI) {
+	// Update config
+	u := tgbotapi.NewUpdate(0)
+	// Long polling leaderRetryInterval'dan kısa tutulur: liderliği kaybeden örneğin bekleyen isteği,
+	// yeni lider kilidi alıp polling'e başlamadan biter ve Telegram 409 çakışması döndürmez
+	u.Timeout = int(leaderRetryInterval/time.Second) / 2
+
+	// Heartbeat poller'ın canlı olduğunu buradan izler
+	pollerActive.Store(true)
+	lastPollAt.Store(time.Now().Unix())
+	defer pollerActive.Store(false)
+
+	for ctx.Err() == nil {
+		updates, err := bot.GetUpdates(u)
+		if err != nil {
+			log.Printf("Update alınamadı, 3 saniye sonra tekrar denenecek: %v", err)
+			time.Sleep(3 * time.Second)
 			continue
 		}
+		lastPollAt.Store(time.Now().Unix())
+
+		// Liderlik istek sürerken kaybedildiyse paket işlenmez; offset onaylanmadığından yeni lider aynı güncellemeleri alır
+		if ctx.Err() != nil {
+			break
+		}
 
-		// Normal mesaj
-		if update.Message != nil {
-			log.Printf("Mesaj alındı: user=%d, text=%s", update.Message.From.ID, update.Message.Text)
-			handleMessage(bot, update.Message)
+		for _, update := range updates {
+			if update.UpdateID >= u.Offset {
+				u.Offset = update.UpdateID + 1
+			}
+			handleUpdate(bot, update)
 		}
 	}
+	log.Println("Telegram poller durduruldu (liderlik kaybedildi)")
+}
+
+// handleUpdate tek bir Telegram güncellemesini işler
+func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	log.Printf("Update alındı: %+v", update)
+
+	// Callback query (inline button tıklaması)
+	if update.CallbackQuery != nil {
+		log.Printf("Callback query: user=%d, data=%s", update.CallbackQuery.From.ID, update.CallbackQuery.Data)
+		handleCallback(bot, update.CallbackQuery)
+		return
+	}
+
+	// Normal mesaj
+	if update.Message != nil {
+		log.Printf("Mesaj alındı: user=%d, text=%s", update.Message.From.ID, update.Message.Text)
+		handleMessage(bot, update.Message)
+	}
 }
 
 // handleMessage normal mesajları işler
@@ -474,49 +1109,78 @@ func handleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		log.Printf("Komut alındı: /%s, user=%d, chat=%d", message.Command(), userID, chatID)
 		switch message.Command() {
 		case "start":
-			sendWelcomeMessage(bot, chatID)
+			// Deep link ile gelen hazır ayar: t.me/<bot>?start=preset_<ad>
+			if presetName, ok := strings.CutPrefix(message.CommandArguments(), "preset_"); ok {
+				startPresetBuildProcess(bot, chatID, userID, presetName)
+			} else {
+				sendWelcomeMessage(bot, chatID)
+			}
+		case "preset":
+			handlePresetCommand(bot, chatID, userID, message.CommandArguments())
 		case "build":
 			startBuildProcess(bot, chatID, userID)
 		case "cancel":
 			cancelSession(bot, chatID, userID)
 		case "myid":
 			sendMyID(bot, chatID, userID)
+		case "kota":
+			handleKotaCommand(bot, chatID, userID)
 		case "toplam":
-			handleToplamCommand(bot, chatID, message.CommandArguments())
+			handleToplamCommand(bot, chatID, userID, message.CommandArguments())
 		case "kaynaklar":
-			handleKaynaklarCommand(bot, chatID, message.CommandArguments())
+			handleKaynaklarCommand(bot, chatID, userID, message.CommandArguments())
 		case "kampanyalar":
-			handleKampanyalarCommand(bot, chatID, message.CommandArguments())
+			handleKampanyalarCommand(bot, chatID, userID, message.CommandArguments())
+		case "rapor":
+			handleRaporCommand(bot, chatID, userID, message.CommandArguments())
+		case "etiket":
+			handleEtiketCommand(bot, chatID, userID, message.CommandArguments())
 		case "ortamlar":
-			handleOrtamlarCommand(bot, chatID, message.CommandArguments())
+			handleOrtamlarCommand(bot, chatID, userID, message.CommandArguments())
+		case "ara":
+			handleAraCommand(bot, chatID, userID, message.CommandArguments())
+		case "sorgu":
+			handleSorguCommand(bot, chatID, userID, message.CommandArguments())
 		case "son":
-			handleSonCommand(bot, chatID, message.CommandArguments())
+			handleSonCommand(bot, chatID, userID, message.CommandArguments())
 		case "gunluk":
-			handleGunlukCommand(bot, chatID)
+			handleGunlukCommand(bot, chatID, userID)
+		case "gun":
+			handleGunCommand(bot, chatID, userID, message.CommandArguments())
 		case "ortalama":
-			handleOrtalamaCommand(bot, chatID, message.CommandArguments())
+			handleOrtalamaCommand(bot, chatID, userID, message.CommandArguments())
 		case "export":
-			handleExportCommand(bot, chatID, message.CommandArguments())
+			handleExportCommand(bot, chatID, userID, message.CommandArguments())
 		case "analiz":
-			handleAnalizCommand(bot, chatID, message.CommandArguments())
+			handleAnalizCommand(bot, chatID, userID, message.CommandArguments())
 		case "kalem":
-			handleKalemCommand(bot, chatID, message.CommandArguments())
+			handleKalemCommand(bot, chatID, userID, message.CommandArguments())
+		case "gads_kontrol":
+			handleGadsKontrolCommand(bot, chatID, userID, message.CommandArguments())
 		case "google":
-			handleSourceAnalysisCommand(bot, chatID, "google")
+			handleSourceAnalysisCommand(bot, chatID, userID, "google")
 		case "meta":
-			handleSourceAnalysisCommand(bot, chatID, "meta")
+			handleSourceAnalysisCommand(bot, chatID, userID, "meta")
 		case "bugun":
-			handleBugunCommand(bot, chatID)
+			handleBugunCommand(bot, chatID, userID)
 		case "dun":
-			handleDunCommand(bot, chatID)
+			handleDunCommand(bot, chatID, userID)
 		case "sms-bugun":
-			handleSMSBugunCommand(bot, chatID)
+			handleSMSBugunCommand(bot, chatID, userID)
 		case "mail-bugun":
-			handleMailBugunCommand(bot, chatID)
+			handleMailBugunCommand(bot, chatID, userID)
 		case "sms":
-			handleSMSCommand(bot, chatID, message.CommandArguments())
+			handleSMSCommand(bot, chatID, userID, message.CommandArguments())
 		case "mail":
-			handleMailCommand(bot, chatID, message.CommandArguments())
+			handleMailCommand(bot, chatID, userID, message.CommandArguments())
+		case "kapsam":
+			handleKapsamCommand(bot, chatID, userID, message.CommandArguments())
+		case "hedef":
+			handleHedefCommand(bot, chatID, userID, message.CommandArguments())
+		case "bildirim":
+			handleBildirimCommand(bot, chatID, userID, message.CommandArguments())
+		case "ornek_veri":
+			handleOrnekVeriCommand(bot, chatID, userID, message.CommandArguments())
 		default:
 			msg := tgbotapi.NewMessage(chatID, "Bilinmeyen komut. /start komutu ile kullanılabilir komutları görebilirsiniz.")
 			bot.Send(msg)
@@ -549,8 +1213,9 @@ Bu Chat ID'yi NOTIFICATION_CHAT_ID olarak kullanabilirsiniz.`, chatID, userID)
 }
 
 // handleToplamCommand /toplam komutunu işler
-func handleToplamCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleToplamCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
 	args = strings.TrimSpace(args)
 
 	var startDate, endDate time.Time
@@ -599,7 +1264,7 @@ func handleToplamCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 
 	// Para birimi bazında toplam
 	query := db.NewSelect().
-		TableExpr("orders").
+		TableExpr("(?) AS orders", scope.orders()).
 		ColumnExpr("currency").
 		ColumnExpr("SUM(amount) as total").
 		ColumnExpr("COUNT(*) as count").
@@ -628,7 +1293,7 @@ func handleToplamCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	sb.WriteString("📊 <b>Bağış Özeti</b>\n\n")
 
 	if hasDateFilter {
-		sb.WriteString(fmt.Sprintf("📅 <b>Tarih Aralığı:</b> %s - %s\n\n",
+		sb.WriteString(htmlf("📅 <b>Tarih Aralığı:</b> %s - %s\n\n",
 			startDate.Format("02.01.2006"),
 			endDate.Format("02.01.2006")))
 	} else {
@@ -638,11 +1303,11 @@ func handleToplamCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	if orderCount == 0 {
 		sb.WriteString("ℹ️ Bu dönemde bağış bulunmamaktadır.")
 	} else {
-		sb.WriteString(fmt.Sprintf("🛒 <b>Toplam Bağış Sayısı:</b> %d\n\n", orderCount))
+		sb.WriteString(htmlf("🛒 <b>Toplam Bağış Sayısı:</b> %d\n\n", orderCount))
 
 		sb.WriteString("💰 <b>Para Birimi Bazında:</b>\n")
 		for _, ct := range currencyTotals {
-			sb.WriteString(fmt.Sprintf("  • %s: %.2f (%d bağış)\n", ct.Currency, ct.Total, ct.Count))
+			sb.WriteString(htmlf("  • %s: %s (%d bağış)\n", ct.Currency, formatAmount(ct.Total, ct.Currency), ct.Count))
 		}
 	}
 
@@ -652,29 +1317,20 @@ func handleToplamCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 }
 
 // handleKaynaklarCommand /kaynaklar komutunu işler - UTM source bazlı analiz
-func handleKaynaklarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleKaynaklarCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	ctx := context.Background()
-	startDate, endDate, hasDateFilter := parseDateRange(args)
-
-	var sources []struct {
-		UTMSource string  `bun:"utm_source"`
-		Total     float64 `bun:"total"`
-		Count     int     `bun:"count"`
+	r, err := parseReportArgs(args)
+	if err != nil {
+		sendReportArgsError(bot, chatID, err)
+		return
 	}
-
-	query := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(utm_source, 'Bilinmiyor') as utm_source").
-		ColumnExpr("SUM(amount) as total").
-		ColumnExpr("COUNT(*) as count").
-		GroupExpr("utm_source").
-		OrderExpr("total DESC")
-
-	if hasDateFilter {
-		query = query.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
+	if r.Group != nil {
+		sendTagGroupReport(bot, chatID, userID, r, "Kaynak Bazlı Analiz", tagBreakdownSource, false)
+		return
 	}
+	startDate, endDate, hasDateFilter := r.StartDate, r.EndDate, r.HasDateFilter
 
-	err := query.Scan(ctx, &sources)
+	sources, err := querySourceTotals(ctx, getDataScope(ctx, chatID, userID), startDate, endDate, hasDateFilter)
 	if err != nil {
 		log.Printf("Kaynaklar sorgu hatası: %v", err)
 		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
@@ -682,29 +1338,30 @@ func handleKaynaklarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		return
 	}
 
-	// Toplam hesapla
-	var grandTotal float64
+	// Toplamlar ve yüzdeler para birimi bazında hesaplanır
+	var grandTotals moneyTotals
 	for _, s := range sources {
-		grandTotal += s.Total
+		grandTotals.add(s.Currency, s.Total, s.Count)
 	}
+	grandTotals.sort()
 
 	var sb strings.Builder
 	sb.WriteString("📊 <b>Kaynak Bazlı Analiz (UTM Source)</b>\n\n")
 
 	if hasDateFilter {
-		sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
+		sb.WriteString(htmlf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
 	}
 
 	if len(sources) == 0 {
 		sb.WriteString("ℹ️ Bu dönemde veri bulunmamaktadır.")
 	} else {
-		for i, s := range sources {
-			percentage := (s.Total / grandTotal) * 100
-			emoji := getEmojiByRank(i)
-			sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, s.UTMSource))
-			sb.WriteString(fmt.Sprintf("   💰 %.2f TRY (%d bağış) - %%%.1f\n\n", s.Total, s.Count, percentage))
+		sections := currencySections{multiple: len(grandTotals) > 1}
+		for _, s := range sources {
+			emoji := getEmojiByRank(sections.next(&sb, s.Currency))
+			sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, s.UTMSource))
+			sb.WriteString(htmlf("   💰 %s (%d bağış) - %%%.1f\n\n", formatMoney(s.Total, s.Currency), s.Count, grandTotals.share(s.Currency, s.Total)))
 		}
-		sb.WriteString(fmt.Sprintf("📈 <b>Toplam:</b> %.2f TRY", grandTotal))
+		sb.WriteString(htmlf("📈 <b>Toplam:</b> %s", grandTotals.String()))
 	}
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
@@ -712,56 +1369,115 @@ func handleKaynaklarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	bot.Send(msg)
 }
 
-// handleKampanyalarCommand /kampanyalar komutunu işler - Kampanya performansı
-func handleKampanyalarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
-	ctx := context.Background()
-	startDate, endDate, hasDateFilter := parseDateRange(args)
+// sourceTotal kaynak ve para birimi bazlı toplam satırı
+type sourceTotal struct {
+	UTMSource string  `bun:"utm_source"`
+	Currency  string  `bun:"currency"`
+	Total     float64 `bun:"total"`
+	Count     int     `bun:"count"`
+}
 
-	var campaigns []struct {
-		UTMCampaign string  `bun:"utm_campaign"`
-		Total       float64 `bun:"total"`
-		Count       int     `bun:"count"`
-		AvgAmount   float64 `bun:"avg_amount"`
-	}
+// querySourceTotals UTM source ve para birimi bazlı toplamları döner (/kaynaklar ve CSV raporu)
+func querySourceTotals(ctx context.Context, scope DataScope, startDate, endDate time.Time, hasDateFilter bool) ([]sourceTotal, error) {
+	var sources []sourceTotal
 
 	query := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(utm_campaign, 'Bilinmiyor') as utm_campaign").
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
 		ColumnExpr("SUM(amount) as total").
 		ColumnExpr("COUNT(*) as count").
-		ColumnExpr("AVG(amount) as avg_amount").
-		GroupExpr("utm_campaign").
-		OrderExpr("total DESC").
-		Limit(10)
+		GroupExpr("currency")
 
 	if hasDateFilter {
 		query = query.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
 	}
 
-	err := query.Scan(ctx, &campaigns)
-	if err != nil {
-		log.Printf("Kampanyalar sorgu hatası: %v", err)
-		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
-		bot.Send(msg)
-		return
-	}
+	err := dimensionTotalsQuery(query, "utm_source", "currency", "total", "count").
+		OrderExpr(currencyGroupOrder).
+		OrderExpr("total DESC").
+		Scan(ctx, &sources)
+	return sources, err
+}
 
-	var sb strings.Builder
-	sb.WriteString("🎯 <b>Kampanya Performansı (Top 10)</b>\n\n")
+// campaignTotal kampanya ve para birimi bazlı toplam satırı
+type campaignTotal struct {
+	UTMCampaign string  `bun:"utm_campaign"`
+	Currency    string  `bun:"currency"`
+	Total       float64 `bun:"total"`
+	Count       int     `bun:"count"`
+	AvgAmount   float64 `bun:"avg_amount"`
+}
 
-	if hasDateFilter {
-		sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
-	}
+// queryCampaignTotals kampanya ve para birimi bazlı toplamları döner (limit 0 ise tümü)
+func queryCampaignTotals(ctx context.Context, scope DataScope, startDate, endDate time.Time, hasDateFilter bool, limit int) ([]campaignTotal, error) {
+	var campaigns []campaignTotal
 
-	if len(campaigns) == 0 {
-		sb.WriteString("ℹ️ Bu dönemde kampanya verisi bulunmamaktadır.")
-	} else {
-		for i, c := range campaigns {
-			emoji := getEmojiByRank(i)
-			sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, c.UTMCampaign))
-			sb.WriteString(fmt.Sprintf("   💰 %.2f TRY | 🛒 %d bağış | 📊 Ort: %.2f TRY\n\n", c.Total, c.Count, c.AvgAmount))
-		}
-	}
+	query := db.NewSelect().
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
+		ColumnExpr("SUM(amount) as total").
+		ColumnExpr("COUNT(*) as count").
+		ColumnExpr("AVG(amount) as avg_amount").
+		GroupExpr("currency")
+
+	if hasDateFilter {
+		query = query.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
+	}
+
+	outer := dimensionTotalsQuery(query, "utm_campaign", "currency", "total", "count", "avg_amount").
+		OrderExpr(currencyGroupOrder).
+		OrderExpr("total DESC")
+	if limit > 0 {
+		outer = outer.Limit(limit)
+	}
+
+	err := outer.Scan(ctx, &campaigns)
+	return campaigns, err
+}
+
+// handleKampanyalarCommand /kampanyalar komutunu işler - Kampanya performansı
+func handleKampanyalarCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+	r, err := parseReportArgs(args)
+	if err != nil {
+		sendReportArgsError(bot, chatID, err)
+		return
+	}
+	if r.Group != nil {
+		sendTagGroupReport(bot, chatID, userID, r, "Kampanya Performansı", tagBreakdownCampaign, false)
+		return
+	}
+	startDate, endDate, hasDateFilter := r.StartDate, r.EndDate, r.HasDateFilter
+
+	campaigns, err := queryCampaignTotals(ctx, getDataScope(ctx, chatID, userID), startDate, endDate, hasDateFilter, 10)
+	if err != nil {
+		log.Printf("Kampanyalar sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎯 <b>Kampanya Performansı (Top 10)</b>\n\n")
+
+	if hasDateFilter {
+		sb.WriteString(htmlf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
+	}
+
+	if len(campaigns) == 0 {
+		sb.WriteString("ℹ️ Bu dönemde kampanya verisi bulunmamaktadır.")
+	} else {
+		var currencies moneyTotals
+		for _, c := range campaigns {
+			currencies.add(c.Currency, c.Total, c.Count)
+		}
+		sections := currencySections{multiple: len(currencies) > 1}
+		for _, c := range campaigns {
+			emoji := getEmojiByRank(sections.next(&sb, c.Currency))
+			sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, c.UTMCampaign))
+			sb.WriteString(htmlf("   💰 %s | 🛒 %d bağış | 📊 Ort: %s\n\n", formatMoney(c.Total, c.Currency), c.Count, formatMoney(c.AvgAmount, c.Currency)))
+		}
+	}
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
 	msg.ParseMode = "HTML"
@@ -769,29 +1485,42 @@ func handleKampanyalarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 }
 
 // handleOrtamlarCommand /ortamlar komutunu işler - UTM medium bazlı analiz
-func handleOrtamlarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleOrtamlarCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	ctx := context.Background()
-	startDate, endDate, hasDateFilter := parseDateRange(args)
+	scope := getDataScope(ctx, chatID, userID)
+	r, err := parseReportArgs(args)
+	if err != nil {
+		sendReportArgsError(bot, chatID, err)
+		return
+	}
+	if r.Group != nil {
+		sendTagGroupReport(bot, chatID, userID, r, "Reklam Ortamı Analizi", tagBreakdownMedium, false)
+		return
+	}
+	startDate, endDate, hasDateFilter := r.StartDate, r.EndDate, r.HasDateFilter
 
 	var mediums []struct {
 		UTMMedium string  `bun:"utm_medium"`
+		Currency  string  `bun:"currency"`
 		Total     float64 `bun:"total"`
 		Count     int     `bun:"count"`
 	}
 
 	query := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(utm_medium, 'Bilinmiyor') as utm_medium").
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
 		ColumnExpr("SUM(amount) as total").
 		ColumnExpr("COUNT(*) as count").
-		GroupExpr("utm_medium").
-		OrderExpr("total DESC")
+		GroupExpr("currency")
 
 	if hasDateFilter {
 		query = query.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
 	}
 
-	err := query.Scan(ctx, &mediums)
+	err = dimensionTotalsQuery(query, "utm_medium", "currency", "total", "count").
+		OrderExpr(currencyGroupOrder).
+		OrderExpr("total DESC").
+		Scan(ctx, &mediums)
 	if err != nil {
 		log.Printf("Ortamlar sorgu hatası: %v", err)
 		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
@@ -799,28 +1528,30 @@ func handleOrtamlarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		return
 	}
 
-	var grandTotal float64
+	var grandTotals moneyTotals
 	for _, m := range mediums {
-		grandTotal += m.Total
+		grandTotals.add(m.Currency, m.Total, m.Count)
 	}
+	grandTotals.sort()
 
 	var sb strings.Builder
 	sb.WriteString("📡 <b>Reklam Ortamı Analizi (UTM Medium)</b>\n\n")
 
 	if hasDateFilter {
-		sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
+		sb.WriteString(htmlf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
 	}
 
 	if len(mediums) == 0 {
 		sb.WriteString("ℹ️ Bu dönemde veri bulunmamaktadır.")
 	} else {
+		sections := currencySections{multiple: len(grandTotals) > 1}
 		for _, m := range mediums {
-			percentage := (m.Total / grandTotal) * 100
+			sections.next(&sb, m.Currency)
 			emoji := getMediumEmoji(m.UTMMedium)
-			sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, m.UTMMedium))
-			sb.WriteString(fmt.Sprintf("   💰 %.2f TRY (%d bağış) - %%%.1f\n\n", m.Total, m.Count, percentage))
+			sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, m.UTMMedium))
+			sb.WriteString(htmlf("   💰 %s (%d bağış) - %%%.1f\n\n", formatMoney(m.Total, m.Currency), m.Count, grandTotals.share(m.Currency, m.Total)))
 		}
-		sb.WriteString(fmt.Sprintf("📈 <b>Toplam:</b> %.2f TRY", grandTotal))
+		sb.WriteString(htmlf("📈 <b>Toplam:</b> %s", grandTotals.String()))
 	}
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
@@ -829,8 +1560,9 @@ func handleOrtamlarCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 }
 
 // handleSonCommand /son komutunu işler - Son N bağış
-func handleSonCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleSonCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
 
 	// Varsayılan 5, argüman varsa onu kullan
 	limit := 5
@@ -841,8 +1573,7 @@ func handleSonCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	}
 
 	var orders []Order
-	err := db.NewSelect().
-		Model(&orders).
+	err := scope.orderModels(&orders).
 		OrderExpr("event_time DESC").
 		Limit(limit).
 		Scan(ctx)
@@ -855,25 +1586,25 @@ func handleSonCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	}
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🕐 <b>Son %d Bağış</b>\n\n", limit))
+	sb.WriteString(htmlf("🕐 <b>Son %d Bağış</b>\n\n", limit))
 
 	if len(orders) == 0 {
 		sb.WriteString("ℹ️ Henüz bağış bulunmamaktadır.")
 	} else {
 		for i, o := range orders {
-			sb.WriteString(fmt.Sprintf("<b>%d.</b> 💰 %.2f %s\n", i+1, o.Amount, o.Currency))
-			sb.WriteString(fmt.Sprintf("   📅 %s\n", o.EventTime.Format("02.01.2006 15:04")))
+			sb.WriteString(htmlf("<b>%d.</b> 💰 %s\n", i+1, formatMoney(o.Amount, o.Currency)))
+			sb.WriteString(htmlf("   📅 %s\n", o.EventTime.Format("02.01.2006 15:04")))
 			if o.UTMSource != "" {
-				sb.WriteString(fmt.Sprintf("   📊 %s / %s\n", o.UTMSource, o.UTMMedium))
+				sb.WriteString(htmlf("   📊 %s / %s\n", o.UTMSource, o.UTMMedium))
 			}
 			if o.UTMCampaign != "" {
-				sb.WriteString(fmt.Sprintf("   🎯 %s\n", o.UTMCampaign))
+				sb.WriteString(htmlf("   🎯 %s\n", o.UTMCampaign))
 			}
 			if o.GadSource != "" || o.GadCampaignID != "" {
-				sb.WriteString(fmt.Sprintf("   🔍 Google: %s / %s\n", o.GadSource, o.GadCampaignID))
+				sb.WriteString(htmlf("   🔍 Google: %s / %s\n", o.GadSource, o.GadCampaignID))
 			}
 			if o.TrafficChannel != "" {
-				sb.WriteString(fmt.Sprintf("   📡 Kanal: %s\n", o.TrafficChannel))
+				sb.WriteString(htmlf("   📡 Kanal: %s\n", o.TrafficChannel))
 			}
 			sb.WriteString("\n")
 		}
@@ -885,52 +1616,18 @@ func handleSonCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 }
 
 // handleGunlukCommand /gunluk komutunu işler - Bugünün özeti
-func handleGunlukCommand(bot *tgbotapi.BotAPI, chatID int64) {
+func handleGunlukCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
 	ctx := context.Background()
 
-	// Türkiye saatine göre bugünün UTC aralığını al
-	startOfDayUTC, endOfDayUTC, now := getDayRangeUTC(0)
-
-	// Genel istatistikler
-	var stats struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
-	err := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(SUM(amount), 0) as total").
-		ColumnExpr("COUNT(*) as count").
-		Where("event_time >= ?", startOfDayUTC).
-		Where("event_time < ?", endOfDayUTC).
-		Scan(ctx, &stats)
-
+	// Bugünün toplamları bellekteki read-model'den okunur (Postgres'e gitmeden)
+	stats, err := getTodaySnapshot(ctx, getDataScope(ctx, chatID, userID))
 	if err != nil {
 		log.Printf("Günlük sorgu hatası: %v", err)
 		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
 		bot.Send(msg)
 		return
 	}
-
-	// Kaynak bazlı dağılım (traffic_channel ile birlikte)
-	var sources []struct {
-		UTMSource string  `bun:"utm_source"`
-		Total     float64 `bun:"total"`
-		Count     int     `bun:"count"`
-	}
-	db.NewRaw(`
-		SELECT 
-			CASE 
-				WHEN utm_source IS NOT NULL AND utm_source != '' THEN utm_source
-				WHEN traffic_channel = 'google' THEN 'Google Ads'
-				ELSE 'Doğrudan'
-			END as utm_source,
-			SUM(amount) as total,
-			COUNT(*) as count
-		FROM orders
-		WHERE event_time >= ? AND event_time < ?
-		GROUP BY 1
-		ORDER BY total DESC
-	`, startOfDayUTC, endOfDayUTC).Scan(ctx, &sources)
+	now := getTurkeyNow()
 
 	// Türkçe gün adı
 	gunAdi := getTurkishDayName(now.Weekday())
@@ -939,35 +1636,163 @@ func handleGunlukCommand(bot *tgbotapi.BotAPI, chatID int64) {
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 	sb.WriteString("☀️ <b>GÜNLÜK RAPOR</b>\n")
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-	sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s, %s\n", now.Format("02 Ocak 2006"), gunAdi))
-	sb.WriteString(fmt.Sprintf("🕐 <b>Saat:</b> %s\n\n", now.Format("15:04")))
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s, %s\n", formatTurkishDate(now), gunAdi))
+	sb.WriteString(htmlf("🕐 <b>Saat:</b> %s\n\n", now.Format("15:04")))
+
+	writeDailyReport(&sb, stats, nil, "ℹ️ Bugün henüz bağış bulunmamaktadır.")
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
 
+// writeDailyReport günlük raporun özet, kaynak, kalem ve kampanya bölümlerini yazar (/gunluk ve /gun ortak)
+// items nil ise kalem bölümü atlanır
+func writeDailyReport(sb *strings.Builder, stats todaySnapshot, items []aggregateBucket, emptyText string) {
 	if stats.Count == 0 {
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
-		sb.WriteString("ℹ️ Bugün henüz bağış bulunmamaktadır.\n")
+		sb.WriteString(emptyText + "\n")
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 	} else {
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 		sb.WriteString("💰 <b>GENEL ÖZET</b>\n")
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-		sb.WriteString(fmt.Sprintf("   🛒 Bağış Sayısı    : <b>%d</b>\n", stats.Count))
-		sb.WriteString(fmt.Sprintf("   💵 Toplam Tutar    : <b>%.2f TRY</b>\n", stats.Total))
-		sb.WriteString(fmt.Sprintf("   📊 Ortalama        : <b>%.2f TRY</b>\n\n", stats.Total/float64(stats.Count)))
+		sb.WriteString(htmlf("   🛒 Bağış Sayısı    : <b>%d</b>\n", stats.Count))
+		sb.WriteString(htmlf("   💵 Toplam Tutar    : <b>%s</b>\n", stats.Totals.String()))
+		sb.WriteString(htmlf("   📊 Ortalama        : <b>%s</b>\n\n", stats.Totals.averages()))
+		multiple := len(stats.Totals) > 1
 
-		if len(sources) > 0 {
+		if len(stats.Sources) > 0 {
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 			sb.WriteString("📡 <b>KAYNAK DAĞILIMI</b>\n")
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-			for i, s := range sources {
-				emoji := getEmojiByRank(i)
-				percentage := (s.Total / stats.Total) * 100
-				sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, s.UTMSource))
-				sb.WriteString(fmt.Sprintf("   └ %.2f TRY | %d bağış | %%%.1f\n\n", s.Total, s.Count, percentage))
+			sections := currencySections{multiple: multiple}
+			for _, s := range stats.Sources {
+				emoji := getEmojiByRank(sections.next(sb, s.Currency))
+				sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, s.Name))
+				sb.WriteString(htmlf("   └ %s | %d bağış | %%%.1f\n\n", formatMoney(s.Total, s.Currency), s.Count, stats.Totals.share(s.Currency, s.Total)))
+			}
+		}
+
+		if len(items) > 0 {
+			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+			sb.WriteString("📦 <b>BAĞIŞ KALEMLERİ (Top 5)</b>\n")
+			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+			sections := currencySections{multiple: multiple}
+			for _, item := range items {
+				emoji := getEmojiByRank(sections.next(sb, item.Currency))
+				sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, item.Name))
+				sb.WriteString(htmlf("   └ %s | %d adet\n\n", formatMoney(item.Total, item.Currency), item.Count))
+			}
+		}
+
+		if len(stats.Campaigns) > 0 {
+			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+			sb.WriteString("🎯 <b>KAMPANYALAR (Top 5)</b>\n")
+			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+			sections := currencySections{multiple: multiple}
+			for _, c := range stats.Campaigns {
+				if sections.next(sb, c.Currency) >= 5 {
+					continue
+				}
+				sb.WriteString(htmlf("   • <b>%s</b>\n", c.Name))
+				sb.WriteString(htmlf("     └ %s | %d bağış\n\n", formatMoney(c.Total, c.Currency), c.Count))
 			}
 		}
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 	}
+}
+
+// queryTopItems verilen gün aralığında her para biriminde tutara göre en çok bağış alan kalemleri döner
+func queryTopItems(ctx context.Context, scope DataScope, startUTC, endUTC time.Time, limit int) ([]aggregateBucket, error) {
+	var rows []struct {
+		ItemName string  `bun:"item_name"`
+		Currency string  `bun:"currency"`
+		Total    float64 `bun:"total"`
+		Count    int     `bun:"count"`
+	}
+	err := db.NewRaw(`
+		SELECT item_name, currency, total, count FROM (
+			SELECT
+				oi.item_name,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count,
+				ROW_NUMBER() OVER (PARTITION BY o.currency ORDER BY SUM(oi.price * oi.quantity) DESC) as rank
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			WHERE o.event_time >= ? AND o.event_time < ?
+			GROUP BY oi.item_name, o.currency
+		) AS items
+		WHERE rank <= ?
+		ORDER BY ?, total DESC
+	`, scope.orders(), startUTC, endUTC, limit, bun.Safe(currencyGroupOrder)).Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]aggregateBucket, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, aggregateBucket{Name: r.ItemName, Currency: r.Currency, Total: r.Total, Count: r.Count})
+	}
+	return items, nil
+}
+
+// handleGunCommand /gun DD.MM.YYYY komutunu işler - Geçmiş bir günün /gunluk formatındaki raporu
+func handleGunCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Lütfen tarih belirtin.\n\nKullanım: <code>/gun DD.MM.YYYY</code>\n\nÖrnek: <code>/gun 15.03.2025</code>")
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	turkeyLoc := getTurkeyLocation()
+	targetDay, err := time.ParseInLocation("02.01.2006", args, turkeyLoc)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Geçersiz tarih formatı.\n\nDoğru format: <code>DD.MM.YYYY</code>\n\nÖrnek: <code>/gun 15.03.2025</code>")
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+	if targetDay.After(getTurkeyNow()) {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Gelecekteki bir gün için rapor oluşturulamaz.")
+		bot.Send(msg)
+		return
+	}
+
+	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
+
+	aggregate, err := buildDayAggregate(ctx, scope, targetDay)
+	if err != nil {
+		log.Printf("Gün raporu sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	startUTC, endUTC := dayRangeUTC(targetDay)
+	items, err := queryTopItems(ctx, scope, startUTC, endUTC, 5)
+	if err != nil {
+		log.Printf("Gün raporu kalem sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	gunAdi := getTurkishDayName(targetDay.Weekday())
+
+	var sb strings.Builder
+	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+	sb.WriteString("📅 <b>GÜN RAPORU</b>\n")
+	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s, %s\n\n", formatTurkishDate(targetDay), gunAdi))
+
+	writeDailyReport(&sb, aggregate.snapshot(), items, "ℹ️ Bu tarihte bağış bulunmamaktadır.")
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
 	msg.ParseMode = "HTML"
@@ -988,6 +1813,30 @@ func getTurkishDayName(day time.Weekday) string {
 	return days[day]
 }
 
+// getTurkishMonthName ay adını Türkçe döner
+func getTurkishMonthName(month time.Month) string {
+	months := map[time.Month]string{
+		time.January:   "Ocak",
+		time.February:  "Şubat",
+		time.March:     "Mart",
+		time.April:     "Nisan",
+		time.May:       "Mayıs",
+		time.June:      "Haziran",
+		time.July:      "Temmuz",
+		time.August:    "Ağustos",
+		time.September: "Eylül",
+		time.October:   "Ekim",
+		time.November:  "Kasım",
+		time.December:  "Aralık",
+	}
+	return months[month]
+}
+
+// formatTurkishDate tarihi "02 Ocak 2006" biçiminde Türkçe ay adıyla döner
+func formatTurkishDate(t time.Time) string {
+	return fmt.Sprintf("%02d %s %d", t.Day(), getTurkishMonthName(t.Month()), t.Year())
+}
+
 // getTurkeyLocation Türkiye timezone'unu döner (UTC+3)
 func getTurkeyLocation() *time.Location {
 	return time.FixedZone("Europe/Istanbul", 3*60*60)
@@ -1001,46 +1850,65 @@ func getTurkeyNow() time.Time {
 // getDayRangeUTC belirli bir gün için UTC zaman aralığını döner
 // dayOffset: 0 = bugün, -1 = dün, 1 = yarın
 func getDayRangeUTC(dayOffset int) (startUTC, endUTC time.Time, turkeyDate time.Time) {
+	targetDay := getTurkeyNow().AddDate(0, 0, dayOffset)
+	startUTC, endUTC = dayRangeUTC(targetDay)
+	return startUTC, endUTC, targetDay
+}
+
+// dayRangeUTC verilen günün Türkiye saatine göre başlangıç/bitişini UTC olarak döner
+func dayRangeUTC(day time.Time) (startUTC, endUTC time.Time) {
 	turkeyLoc := getTurkeyLocation()
-	now := time.Now().In(turkeyLoc)
-	targetDay := now.AddDate(0, 0, dayOffset)
+	day = day.In(turkeyLoc)
 
 	// Türkiye'de günün başlangıcı (00:00 TR)
-	startOfDayTR := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, turkeyLoc)
+	startOfDayTR := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, turkeyLoc)
 	// Türkiye'de günün sonu (24:00 TR = ertesi gün 00:00)
 	endOfDayTR := startOfDayTR.AddDate(0, 0, 1)
 
 	// UTC'ye çevir
-	return startOfDayTR.UTC(), endOfDayTR.UTC(), targetDay
+	return startOfDayTR.UTC(), endOfDayTR.UTC()
 }
 
 // handleOrtalamaCommand /ortalama komutunu işler - Ortalama bağış analizi
-func handleOrtalamaCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleOrtalamaCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	ctx := context.Background()
-	startDate, endDate, hasDateFilter := parseDateRange(args)
+	scope := getDataScope(ctx, chatID, userID)
+	r, err := parseReportArgs(args)
+	if err != nil {
+		sendReportArgsError(bot, chatID, err)
+		return
+	}
+	if r.Group != nil {
+		sendTagGroupReport(bot, chatID, userID, r, "Ortalama Bağış Analizi", tagBreakdownSource, true)
+		return
+	}
+	startDate, endDate, hasDateFilter := r.StartDate, r.EndDate, r.HasDateFilter
 
 	// Kaynak bazlı ortalama
 	var sourceAvg []struct {
 		UTMSource string  `bun:"utm_source"`
+		Currency  string  `bun:"currency"`
 		AvgAmount float64 `bun:"avg_amount"`
 		Count     int     `bun:"count"`
 		Total     float64 `bun:"total"`
 	}
 
 	query := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(utm_source, 'Bilinmiyor') as utm_source").
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
 		ColumnExpr("AVG(amount) as avg_amount").
 		ColumnExpr("COUNT(*) as count").
 		ColumnExpr("SUM(amount) as total").
-		GroupExpr("utm_source").
-		OrderExpr("avg_amount DESC")
+		GroupExpr("currency")
 
 	if hasDateFilter {
 		query = query.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
 	}
 
-	err := query.Scan(ctx, &sourceAvg)
+	err = dimensionTotalsQuery(query, "utm_source", "currency", "avg_amount", "count", "total").
+		OrderExpr(currencyGroupOrder).
+		OrderExpr("avg_amount DESC").
+		Scan(ctx, &sourceAvg)
 	if err != nil {
 		log.Printf("Ortalama sorgu hatası: %v", err)
 		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
@@ -1051,30 +1919,37 @@ func handleOrtalamaCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	// Kampanya bazlı ortalama (top 5)
 	var campaignAvg []struct {
 		UTMCampaign string  `bun:"utm_campaign"`
+		Currency    string  `bun:"currency"`
 		AvgAmount   float64 `bun:"avg_amount"`
 		Count       int     `bun:"count"`
 	}
 
 	query2 := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(utm_campaign, 'Bilinmiyor') as utm_campaign").
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
 		ColumnExpr("AVG(amount) as avg_amount").
 		ColumnExpr("COUNT(*) as count").
-		GroupExpr("utm_campaign").
-		OrderExpr("avg_amount DESC").
-		Limit(5)
+		GroupExpr("currency")
 
 	if hasDateFilter {
 		query2 = query2.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
 	}
 
-	query2.Scan(ctx, &campaignAvg)
+	// Ortalamalar sadece aynı para birimi içinde karşılaştırılır, her para biriminin ilk 5'i alınır
+	db.NewSelect().
+		TableExpr("(?) AS c", dimensionTotalsQuery(query2, "utm_campaign", "currency", "avg_amount", "count").
+			ColumnExpr("ROW_NUMBER() OVER (PARTITION BY t.currency ORDER BY t.avg_amount DESC) AS rank")).
+		ColumnExpr("utm_campaign, currency, avg_amount, count").
+		Where("rank <= 5").
+		OrderExpr(currencyGroupOrder).
+		OrderExpr("avg_amount DESC").
+		Scan(ctx, &campaignAvg)
 
 	var sb strings.Builder
 	sb.WriteString("📊 <b>Ortalama Bağış Analizi</b>\n\n")
 
 	if hasDateFilter {
-		sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
+		sb.WriteString(htmlf("📅 <b>Tarih:</b> %s - %s\n\n", startDate.Format("02.01.2006"), endDate.Format("02.01.2006")))
 	}
 
 	if len(sourceAvg) == 0 {
@@ -1082,17 +1957,24 @@ func handleOrtalamaCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	} else {
 		sb.WriteString("<b>🎯 Kaynak Bazlı Ortalama:</b>\n")
 		sb.WriteString("<i>(Hangi kaynak daha kaliteli bağışçı getiriyor?)</i>\n\n")
+		var currencies moneyTotals
+		for _, s := range sourceAvg {
+			currencies.add(s.Currency, s.Total, s.Count)
+		}
+		sections := currencySections{multiple: len(currencies) > 1}
 		for _, s := range sourceAvg {
-			sb.WriteString(fmt.Sprintf("• <b>%s</b>\n", s.UTMSource))
-			sb.WriteString(fmt.Sprintf("  Ort: %.2f TRY | %d bağış | Toplam: %.2f TRY\n\n", s.AvgAmount, s.Count, s.Total))
+			sections.next(&sb, s.Currency)
+			sb.WriteString(htmlf("• <b>%s</b>\n", s.UTMSource))
+			sb.WriteString(htmlf("  Ort: %s | %d bağış | Toplam: %s\n\n", formatMoney(s.AvgAmount, s.Currency), s.Count, formatMoney(s.Total, s.Currency)))
 		}
 
 		if len(campaignAvg) > 0 {
 			sb.WriteString("\n<b>🏆 En Yüksek Ortalama Kampanyalar (Top 5):</b>\n\n")
-			for i, c := range campaignAvg {
-				emoji := getEmojiByRank(i)
-				sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, c.UTMCampaign))
-				sb.WriteString(fmt.Sprintf("   Ort: %.2f TRY (%d bağış)\n\n", c.AvgAmount, c.Count))
+			sections := currencySections{multiple: len(currencies) > 1}
+			for _, c := range campaignAvg {
+				emoji := getEmojiByRank(sections.next(&sb, c.Currency))
+				sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, c.UTMCampaign))
+				sb.WriteString(htmlf("   Ort: %s (%d bağış)\n\n", formatMoney(c.AvgAmount, c.Currency), c.Count))
 			}
 		}
 	}
@@ -1103,18 +1985,41 @@ func handleOrtalamaCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 }
 
 // handleExportCommand /export komutunu işler - Excel export
-func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	ctx := context.Background()
-	startDate, endDate, hasDateFilter := parseDateRange(args)
+
+	// Kota sadece dosya başarıyla gönderildiğinde tüketilir
+	if !enforceQuota(ctx, bot, chatID, userID, "export") {
+		return
+	}
+
+	stopProgress := startProgress(bot, chatID, tgbotapi.ChatUploadDocument, "Excel raporu")
+	defer stopProgress()
+
+	// İki dönem karşılaştırması: /export fark <aralık1> vs <aralık2>
+	if fields := strings.Fields(args); len(fields) > 0 && fields[0] == "fark" {
+		if handleExportDiff(bot, chatID, userID, strings.Join(fields[1:], " ")) {
+			consumeQuota(ctx, userID, "export")
+		}
+		return
+	}
+
+	scope := getDataScope(ctx, chatID, userID)
+	r, err := parseReportArgs(args)
+	if err != nil {
+		sendReportArgsError(bot, chatID, err)
+		return
+	}
+	startDate, endDate, hasDateFilter := r.StartDate, r.EndDate, r.HasDateFilter
 
 	var orders []Order
-	query := db.NewSelect().Model(&orders).OrderExpr("event_time DESC")
+	query := scope.orderModels(&orders).OrderExpr("event_time DESC")
 
 	if hasDateFilter {
 		query = query.Where("event_time >= ?", startDate).Where("event_time <= ?", endDate)
 	}
 
-	err := query.Scan(ctx)
+	err = query.Scan(ctx)
 	if err != nil {
 		log.Printf("Export sorgu hatası: %v", err)
 		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
@@ -1133,38 +2038,7 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	defer f.Close()
 
 	// Stilleri oluştur
-	headerStyle, _ := f.NewStyle(&excelize.Style{
-		Font:      &excelize.Font{Bold: true, Color: "FFFFFF", Size: 11},
-		Fill:      excelize.Fill{Type: "pattern", Color: []string{"4472C4"}, Pattern: 1},
-		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
-
-	dataStyle, _ := f.NewStyle(&excelize.Style{
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-		Alignment: &excelize.Alignment{Vertical: "center"},
-	})
-
-	amountStyle, _ := f.NewStyle(&excelize.Style{
-		NumFmt: 4,
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-		Alignment: &excelize.Alignment{Horizontal: "right", Vertical: "center"},
-	})
+	headerStyle, dataStyle, amountStyle, _ := createExportStyles(f)
 
 	// 1. Ana "Tüm Bağışlar" sheet'i
 	mainSheet := "Tüm Bağışlar"
@@ -1172,20 +2046,20 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	writeOrdersToSheet(f, mainSheet, orders, headerStyle, dataStyle, amountStyle)
 
 	// 2. Bağışları kategorize et:
-	// - UTM Source varsa → UTM sheet'i
+	// - UTM Source varsa → UTM sheet'i (kaynak ID'sine göre)
 	// - UTM Source yok ama GAD Campaign ID varsa → GAD sheet'i (UTM sheet'ine eklenmez)
 	// - Ne UTM ne GAD varsa → Organik sheet'i
-	sourceMap := make(map[string][]Order)
+	sourceMap := make(map[int64][]Order)
 	gadMap := make(map[string][]Order)
 	var organikOrders []Order
 
 	for _, o := range orders {
-		hasUTM := o.UTMSource != ""
+		hasUTM := o.UTMSourceID != 0
 		hasGAD := o.GadCampaignID != ""
 
 		if hasUTM {
 			// UTM kaynaklı bağış
-			sourceMap[o.UTMSource] = append(sourceMap[o.UTMSource], o)
+			sourceMap[o.UTMSourceID] = append(sourceMap[o.UTMSourceID], o)
 		} else if hasGAD {
 			// Sadece GAD kaynaklı bağış (UTM yok)
 			gadMap[o.GadCampaignID] = append(gadMap[o.GadCampaignID], o)
@@ -1196,9 +2070,9 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	}
 
 	// UTM Kaynak sheet'lerini oluştur
-	for source, sourceOrders := range sourceMap {
+	for _, sourceOrders := range sourceMap {
 		if len(sourceOrders) > 0 {
-			sheetName := sanitizeSheetName("Kaynak_" + source)
+			sheetName := sanitizeSheetName("Kaynak_" + sourceOrders[0].UTMSource)
 			f.NewSheet(sheetName)
 			writeOrdersToSheet(f, sheetName, sourceOrders, headerStyle, dataStyle, amountStyle)
 		}
@@ -1242,21 +2116,17 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		f.SetCellValue(summarySheet, "A3", "Dönem: Tüm Zamanlar")
 	}
 
-	// Genel istatistikler
-	var totalAmount float64
-	for _, o := range orders {
-		totalAmount += o.Amount
-	}
-	avgAmount := totalAmount / float64(len(orders))
+	// Genel istatistikler (para birimi bazında)
+	totals := orderTotals(orders)
 
 	f.SetCellValue(summarySheet, "A5", "GENEL İSTATİSTİKLER")
 	f.SetCellStyle(summarySheet, "A5", "A5", subTitleStyle)
 	f.SetCellValue(summarySheet, "A6", "Toplam Bağış Sayısı:")
 	f.SetCellValue(summarySheet, "B6", len(orders))
 	f.SetCellValue(summarySheet, "A7", "Toplam Tutar:")
-	f.SetCellValue(summarySheet, "B7", fmt.Sprintf("%.2f TRY", totalAmount))
+	f.SetCellValue(summarySheet, "B7", totals.String())
 	f.SetCellValue(summarySheet, "A8", "Ortalama Bağış:")
-	f.SetCellValue(summarySheet, "B8", fmt.Sprintf("%.2f TRY", avgAmount))
+	f.SetCellValue(summarySheet, "B8", totals.averages())
 
 	// Kaynak bazlı özet
 	row := 10
@@ -1269,14 +2139,10 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("C%d", row), headerStyle)
 	row++
 
-	for source, sourceOrders := range sourceMap {
-		var sourceTotal float64
-		for _, o := range sourceOrders {
-			sourceTotal += o.Amount
-		}
-		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), source)
+	for _, sourceOrders := range sourceMap {
+		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), sourceOrders[0].UTMSource)
 		f.SetCellValue(summarySheet, fmt.Sprintf("B%d", row), len(sourceOrders))
-		f.SetCellValue(summarySheet, fmt.Sprintf("C%d", row), fmt.Sprintf("%.2f TRY", sourceTotal))
+		f.SetCellValue(summarySheet, fmt.Sprintf("C%d", row), orderTotals(sourceOrders).String())
 		row++
 	}
 
@@ -1293,13 +2159,9 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		row++
 
 		for gadID, gadOrders := range gadMap {
-			var gadTotal float64
-			for _, o := range gadOrders {
-				gadTotal += o.Amount
-			}
 			f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), gadID)
 			f.SetCellValue(summarySheet, fmt.Sprintf("B%d", row), len(gadOrders))
-			f.SetCellValue(summarySheet, fmt.Sprintf("C%d", row), fmt.Sprintf("%.2f TRY", gadTotal))
+			f.SetCellValue(summarySheet, fmt.Sprintf("C%d", row), orderTotals(gadOrders).String())
 			row++
 		}
 	}
@@ -1310,19 +2172,29 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), "ORGANİK BAĞIŞLAR")
 		f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), subTitleStyle)
 		row++
-		var organikTotal float64
-		for _, o := range organikOrders {
-			organikTotal += o.Amount
-		}
 		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", row), "Organik (UTM/GAD yok)")
 		f.SetCellValue(summarySheet, fmt.Sprintf("B%d", row), len(organikOrders))
-		f.SetCellValue(summarySheet, fmt.Sprintf("C%d", row), fmt.Sprintf("%.2f TRY", organikTotal))
+		f.SetCellValue(summarySheet, fmt.Sprintf("C%d", row), orderTotals(organikOrders).String())
 	}
 
 	f.SetColWidth(summarySheet, "A", "A", 30)
 	f.SetColWidth(summarySheet, "B", "B", 15)
 	f.SetColWidth(summarySheet, "C", "C", 20)
 
+	// grupla=<boyut> verilmişse etiket grubu × kaynak sayfası
+	tagSheetCount := 0
+	if r.Group != nil {
+		groups, _, err := queryTagGroups(ctx, scope, r, tagBreakdownSource)
+		if err != nil {
+			log.Printf("Export etiket grubu sorgu hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		writeTagGroupsToSheet(f, sanitizeSheetName("Etiket_"+r.Group.Title), r.Group.Title, groups, headerStyle, subTitleStyle)
+		tagSheetCount = 1
+	}
+
 	// Dosyayı kaydet
 	var filename string
 	if hasDateFilter {
@@ -1344,12 +2216,15 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	if len(organikOrders) > 0 {
 		organikSheetCount = 1
 	}
-	sheetCount := 2 + len(sourceMap) + len(gadMap) + organikSheetCount // Özet + Tüm Bağışlar + kaynaklar + GAD'ler + Organik
+	sheetCount := 2 + len(sourceMap) + len(gadMap) + organikSheetCount + tagSheetCount // Özet + Tüm Bağışlar + kaynaklar + GAD'ler + Organik + Etiket
 
 	// Telegram'a gönder
 	doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
-	doc.Caption = fmt.Sprintf("📊 Bağış Raporu\n📁 %d kayıt | %d sayfa\n💰 Toplam: %.2f TRY\n\n📑 Sayfalar: Özet, Tüm Bağışlar, %d UTM kaynak, %d GAD Campaign, %d Organik",
-		len(orders), sheetCount, totalAmount, len(sourceMap), len(gadMap), organikSheetCount)
+	doc.Caption = fmt.Sprintf("📊 Bağış Raporu\n📁 %d kayıt | %d sayfa\n💰 Toplam: %s\n\n📑 Sayfalar: Özet, Tüm Bağışlar, %d UTM kaynak, %d GAD Campaign, %d Organik",
+		len(orders), sheetCount, totals.String(), len(sourceMap), len(gadMap), organikSheetCount)
+	if r.Group != nil {
+		doc.Caption += fmt.Sprintf(", %s etiket grupları", r.Group.Title)
+	}
 
 	if _, err := bot.Send(doc); err != nil {
 		log.Printf("Dosya gönderme hatası: %v", err)
@@ -1357,13 +2232,14 @@ func handleExportCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		bot.Send(msg)
 		return
 	}
+	consumeQuota(ctx, userID, "export")
 
 	// Geçici dosyayı sil
 	os.Remove(filepath)
 }
 
 // handleAnalizCommand /analiz komutunu işler - UTM linkinden bağış analizi
-func handleAnalizCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleAnalizCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	args = strings.TrimSpace(args)
 
 	if args == "" {
@@ -1401,10 +2277,11 @@ Link içindeki UTM parametreleri (utm_source, utm_medium, utm_campaign) kullanı
 	}
 
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
 
 	// Sorguyu oluştur
 	var orders []Order
-	queryBuilder := db.NewSelect().Model(&orders)
+	queryBuilder := scope.orderModels(&orders)
 
 	// Filtreleri ekle (sadece dolu olanlar)
 	if utmSource != "" {
@@ -1428,10 +2305,7 @@ Link içindeki UTM parametreleri (utm_source, utm_medium, utm_campaign) kullanı
 	}
 
 	// İstatistikleri hesapla
-	var totalAmount float64
-	for _, o := range orders {
-		totalAmount += o.Amount
-	}
+	totals := orderTotals(orders)
 
 	// Mesajı oluştur
 	var sb strings.Builder
@@ -1439,24 +2313,24 @@ Link içindeki UTM parametreleri (utm_source, utm_medium, utm_campaign) kullanı
 
 	sb.WriteString("<b>🎯 Arama Kriterleri:</b>\n")
 	if utmSource != "" {
-		sb.WriteString(fmt.Sprintf("  • utm_source: <code>%s</code>\n", utmSource))
+		sb.WriteString(htmlf("  • utm_source: <code>%s</code>\n", utmSource))
 	}
 	if utmMedium != "" {
-		sb.WriteString(fmt.Sprintf("  • utm_medium: <code>%s</code>\n", utmMedium))
+		sb.WriteString(htmlf("  • utm_medium: <code>%s</code>\n", utmMedium))
 	}
 	if utmCampaign != "" {
-		sb.WriteString(fmt.Sprintf("  • utm_campaign: <code>%s</code>\n", utmCampaign))
+		sb.WriteString(htmlf("  • utm_campaign: <code>%s</code>\n", utmCampaign))
 	}
 	sb.WriteString("\n")
 
 	if len(orders) == 0 {
 		sb.WriteString("ℹ️ Bu kriterlere uyan bağış bulunamadı.")
 	} else {
-		sb.WriteString(fmt.Sprintf("📈 <b>Özet:</b>\n"))
-		sb.WriteString(fmt.Sprintf("  • Toplam Bağış: %d\n", len(orders)))
-		sb.WriteString(fmt.Sprintf("  • Toplam Tutar: %.2f TRY\n", totalAmount))
+		sb.WriteString(htmlf("📈 <b>Özet:</b>\n"))
+		sb.WriteString(htmlf("  • Toplam Bağış: %d\n", len(orders)))
+		sb.WriteString(htmlf("  • Toplam Tutar: %s\n", totals.String()))
 		if len(orders) > 0 {
-			sb.WriteString(fmt.Sprintf("  • Ortalama: %.2f TRY\n", totalAmount/float64(len(orders))))
+			sb.WriteString(htmlf("  • Ortalama: %s\n", totals.averages()))
 		}
 		sb.WriteString("\n")
 
@@ -1465,14 +2339,14 @@ Link içindeki UTM parametreleri (utm_source, utm_medium, utm_campaign) kullanı
 		if len(orders) < limit {
 			limit = len(orders)
 		}
-		sb.WriteString(fmt.Sprintf("🕐 <b>Son %d Bağış:</b>\n", limit))
+		sb.WriteString(htmlf("🕐 <b>Son %d Bağış:</b>\n", limit))
 		for i := 0; i < limit; i++ {
 			o := orders[i]
-			sb.WriteString(fmt.Sprintf("%d. %.2f %s - %s\n", i+1, o.Amount, o.Currency, o.EventTime.Format("02.01.2006 15:04")))
+			sb.WriteString(htmlf("%d. %s - %s\n", i+1, formatMoney(o.Amount, o.Currency), o.EventTime.Format("02.01.2006 15:04")))
 		}
 
 		if len(orders) > 10 {
-			sb.WriteString(fmt.Sprintf("\n<i>...ve %d bağış daha</i>", len(orders)-10))
+			sb.WriteString(htmlf("\n<i>...ve %d bağış daha</i>", len(orders)-10))
 		}
 	}
 
@@ -1562,13 +2436,17 @@ Hoş geldiniz! Bu bot ile web sitesinden gelen bağışları takip edebilir ve r
 /bugun — Bugünün bağışları (kalem + toplam)
 /dun — Dünün bağışları
 /gunluk — Bugünün özeti
+/gun [DD.MM.YYYY] — Geçmiş bir günün özeti
 /son [N] — Son N bağış (varsayılan 5)
+/ara tutar>500 tarih=dun 14:00-15:00 kaynak=meta — Bağış ara
+/sorgu [filtreler] — Filtreye uyan bağışların özeti
 
 ━━━━━━━━━━━━━━━━━━━━━━
 📡 <b>KAYNAK ANALİZİ</b>
 ━━━━━━━━━━━━━━━━━━━━━━
 
 /google — Google Ads analizi
+/gads_kontrol [DD.MM.YYYY - DD.MM.YYYY] — gad_campaignid / Ads kampanya uyumu
 /meta — Meta (FB/IG) analizi
 /kaynaklar — Tüm kaynaklar
 /ortamlar — Reklam ortamları
@@ -1586,8 +2464,10 @@ Hoş geldiniz! Bu bot ile web sitesinden gelen bağışları takip edebilir ve r
 📦 <b>DETAYLI ANALİZ</b>
 ━━━━━━━━━━━━━━━━━━━━━━
 
-/kalem [isim] — Bağış kalemi analizi
+/kalem [isim] — Bağış kalemi analizi (isimsiz: kategori/arama seçicisi)
 /kampanyalar — Kampanya performansı
+/rapor grupla=ulke — Kampanyaları etikete göre grupla (ulke, urun, amac, sahip)
+   grupla= /kaynaklar, /ortamlar, /ortalama ve /export ile de çalışır
 /ortalama — Ortalama bağış analizi
 /analiz [URL] — UTM link analizi
 /toplam — Tüm bağışların özeti
@@ -1598,12 +2478,14 @@ Hoş geldiniz! Bu bot ile web sitesinden gelen bağışları takip edebilir ve r
 
 /export — Tüm verileri Excel'e aktar
 /export DD.MM.YYYY - DD.MM.YYYY
+/export fark [aralık1] vs [aralık2] — İki dönem karşılaştırması
 
 ━━━━━━━━━━━━━━━━━━━━━━
 🔗 <b>UTM OLUŞTURUCU</b>
 ━━━━━━━━━━━━━━━━━━━━━━
 
 /build — Yeni UTM link oluştur
+/preset — Hazır kampanya ayarları ve paylaşım linkleri
 /cancel — İşlemi iptal et
 
 ━━━━━━━━━━━━━━━━━━━━━━
@@ -1611,8 +2493,24 @@ Hoş geldiniz! Bu bot ile web sitesinden gelen bağışları takip edebilir ve r
 ━━━━━━━━━━━━━━━━━━━━━━
 
 /myid — Chat ID'nizi öğrenin
+/kota — Günlük kullanım limitleriniz
 /start — Bu mesajı göster
 
+━━━━━━━━━━━━━━━━━━━━━━
+🔐 <b>YÖNETİCİ</b>
+━━━━━━━━━━━━━━━━━━━━━━
+
+/ornek_veri N [DD.MM.YYYY - DD.MM.YYYY] — Test bağışı üret
+/ornek_veri sil — Test bağışlarını sil
+/etiket [kampanya] ulke=.. urun=.. amac=.. sahip=.. — Kampanya etiketle
+/preset ekle [ad] [source] [medium] [campaign] — Hazır ayar ekle
+/preset sil [ad] — Hazır ayarı sil
+/bildirim [chat_id] genel|tam|otomatik — Bildirim şablonu seç
+/kapsam [chat_id] kampanya=de_ kaynak=meta,google — Sohbetin veri kapsamını sınırla
+/kapsam [chat_id] sil — Veri kapsamını kaldır
+/hedef ekle [kampanya] her=100k [sohbet=...] — Kampanya hedefi duyurusu
+/hedef sil [id] — Kampanya hedefini sil
+
 ━━━━━━━━━━━━━━━━━━━━━━`
 
 	msg := tgbotapi.NewMessage(chatID, welcomeText)
@@ -1622,6 +2520,10 @@ Hoş geldiniz! Bu bot ile web sitesinden gelen bağışları takip edebilir ve r
 
 // startBuildProcess UTM oluşturma sürecini başlatır
 func startBuildProcess(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	if !enforceQuota(context.Background(), bot, chatID, userID, "build") {
+		return
+	}
+
 	// Yeni session oluştur
 	sessionsMutex.Lock()
 	sessions[userID] = &UserSession{Step: 1}
@@ -1654,6 +2556,14 @@ func handleUserInput(bot *tgbotapi.BotAPI, chatID int64, userID int64, text stri
 			return
 		}
 		session.SourceURL = text
+		// Hazır ayarda source/medium/campaign dolu, doğrudan içerik adımına geç
+		if session.Preset != "" {
+			session.Step = 5
+			msg := tgbotapi.NewMessage(chatID, "📝 <b>Adım 2/2: Kreatif Adı (utm_content)</b>\n\nLütfen kreatif/içerik adını girin.\n\n⚠️ <b>Uyarı:</b> Türkçe karakter kullanmayın (ş, ı, ğ, ü, ö, ç)\n\nÖrnek: <code>test_genel_su_kuyusu</code>")
+			msg.ParseMode = "HTML"
+			bot.Send(msg)
+			return
+		}
 		session.Step = 2
 		askUTMSource(bot, chatID)
 
@@ -1666,6 +2576,10 @@ func handleUserInput(bot *tgbotapi.BotAPI, chatID int64, userID int64, text stri
 
 	case 5: // Content
 		session.Content = sanitizeUTMValue(text)
+		if session.Preset != "" {
+			sendFinalURL(bot, chatID, userID, session)
+			return
+		}
 		session.Step = 6
 		askUTMTerm(bot, chatID)
 
@@ -1689,6 +2603,12 @@ func handleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
 	// Callback'i yanıtla (loading göstergesini kaldır)
 	bot.Request(tgbotapi.NewCallback(callback.ID, ""))
 
+	// /kalem seçicisi oturumdan bağımsızdır, önekine göre yönlendirilir
+	if strings.HasPrefix(data, "kalem:") {
+		handleKalemCallback(bot, callback)
+		return
+	}
+
 	sessionsMutex.RLock()
 	session, exists := sessions[userID]
 	// Debug: Mevcut session'ları logla
@@ -1817,17 +2737,20 @@ func sendFinalURL(bot *tgbotapi.BotAPI, chatID int64, userID int64, session *Use
 	var sb strings.Builder
 	sb.WriteString("✅ <b>UTM Link Başarıyla Oluşturuldu!</b>\n\n")
 	sb.WriteString("📊 <b>Parametreler:</b>\n")
-	sb.WriteString(fmt.Sprintf("• Kaynak URL: %s\n", session.SourceURL))
-	sb.WriteString(fmt.Sprintf("• utm_source: %s\n", session.UTMSource))
-	sb.WriteString(fmt.Sprintf("• utm_medium: %s\n", session.UTMMedium))
-	sb.WriteString(fmt.Sprintf("• utm_campaign: %s\n", session.Campaign))
-	sb.WriteString(fmt.Sprintf("• utm_content: %s\n", session.Content))
+	if session.Preset != "" {
+		sb.WriteString(htmlf("• Hazır ayar: %s\n", session.Preset))
+	}
+	sb.WriteString(htmlf("• Kaynak URL: %s\n", session.SourceURL))
+	sb.WriteString(htmlf("• utm_source: %s\n", session.UTMSource))
+	sb.WriteString(htmlf("• utm_medium: %s\n", session.UTMMedium))
+	sb.WriteString(htmlf("• utm_campaign: %s\n", session.Campaign))
+	sb.WriteString(htmlf("• utm_content: %s\n", session.Content))
 
 	if session.Term != "" {
-		sb.WriteString(fmt.Sprintf("• utm_term: %s\n", session.Term))
+		sb.WriteString(htmlf("• utm_term: %s\n", session.Term))
 	}
 
-	sb.WriteString(fmt.Sprintf("\n🔗 <b>Son URL:</b>\n<code>%s</code>\n\n", finalURL))
+	sb.WriteString(htmlf("\n🔗 <b>Son URL:</b>\n<code>%s</code>\n\n", finalURL))
 	sb.WriteString("Yeni bir link oluşturmak için /build komutunu kullanabilirsiniz.")
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
@@ -1839,6 +2762,8 @@ func sendFinalURL(bot *tgbotapi.BotAPI, chatID int64, userID int64, session *Use
 		bot.Send(plainMsg)
 	}
 
+	consumeQuota(context.Background(), userID, "build")
+
 	// Session'ı temizle
 	sessionsMutex.Lock()
 	delete(sessions, userID)
@@ -1894,58 +2819,62 @@ func replaceTurkishChars(s string) string {
 }
 
 // handleKalemCommand /kalem komutunu işler - Bağış kalemi detaylı analizi
-func handleKalemCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleKalemCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	itemName := strings.TrimSpace(args)
 
 	if itemName == "" {
-		// Mevcut bağış kalemlerini listele
+		// Kalem sayısı yüzleri bulabildiği için düz liste yerine sayfalı seçici gösterilir
 		ctx := context.Background()
-		var items []struct {
-			ItemName string `bun:"item_name"`
+		entries, err := loadKalemEntries(ctx, getDataScope(ctx, chatID, userID))
+		if err != nil {
+			log.Printf("Kalem listesi sorgu hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
 		}
-		err := db.NewRaw(`
-			SELECT DISTINCT item->>'item_name' as item_name
-			FROM orders, jsonb_array_elements(items) as item
-			ORDER BY item_name
-		`).Scan(ctx, &items)
-
-		if err != nil || len(items) == 0 {
+		if len(entries) == 0 {
 			msg := tgbotapi.NewMessage(chatID, "❌ Bağış kalemi bulunamadı.")
 			bot.Send(msg)
 			return
 		}
 
-		var sb strings.Builder
-		sb.WriteString("📦 <b>Mevcut Bağış Kalemleri</b>\n\n")
-		sb.WriteString("Detay görmek için:\n<code>/kalem [kalem adı]</code>\n\n")
-		sb.WriteString("<b>Kalemler:</b>\n")
-		for _, item := range items {
-			sb.WriteString(fmt.Sprintf("  • %s\n", item.ItemName))
+		if len(kalemCategories(entries)) > 1 {
+			showKalemCategories(bot, chatID, 0, entries, 0)
+		} else {
+			showKalemList(bot, chatID, 0, entries, "*", 0)
 		}
-
-		msg := tgbotapi.NewMessage(chatID, sb.String())
-		msg.ParseMode = "HTML"
-		bot.Send(msg)
 		return
 	}
 
+	sendKalemReport(bot, chatID, userID, itemName, false)
+}
+
+// sendKalemReport kalemin detay raporunu gönderir
+// exact true ise (seçiciden seçilen kalem) sadece bu ada sahip kalem, değilse adında metin geçen kalemler toplanır
+func sendKalemReport(bot *tgbotapi.BotAPI, chatID int64, userID int64, itemName string, exact bool) {
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
 
-	// Türkiye saatine göre bugünün UTC aralığını al
+	itemFilter := bun.SafeQuery("oi.item_name ILIKE ?", "%"+likeEscaper.Replace(itemName)+"%")
+	if exact {
+		itemFilter = bun.SafeQuery("oi.item_name = ?", itemName)
+	}
+
+	// Türkiye saatine göre bugünün UTC aralığını al
 	startOfDayUTC, endOfDayUTC, now := getDayRangeUTC(0)
 
-	// 1. Tüm zamanlar toplamı
-	var allTimeStats struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
+	// 1. Tüm zamanlar toplamı (para birimi bazında)
+	var allTimeStats moneyTotals
 	err := db.NewRaw(`
 		SELECT 
-			COALESCE(SUM((item->>'price')::numeric * (item->>'quantity')::numeric), 0) as total,
-			COALESCE(SUM((item->>'quantity')::numeric), 0)::int as count
-		FROM orders, jsonb_array_elements(items) as item
-		WHERE item->>'item_name' ILIKE ?
-	`, "%"+itemName+"%").Scan(ctx, &allTimeStats)
+			o.currency,
+			SUM(oi.price * oi.quantity) as total,
+			SUM(oi.quantity)::int as count
+		FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+		WHERE ?
+		GROUP BY o.currency
+		ORDER BY count DESC, o.currency
+	`, scope.orders(), itemFilter).Scan(ctx, &allTimeStats)
 
 	if err != nil {
 		log.Printf("Kalem tüm zamanlar sorgu hatası: %v", err)
@@ -1954,108 +2883,114 @@ func handleKalemCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 		return
 	}
 
-	if allTimeStats.Count == 0 {
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ <b>%s</b> adında bağış kalemi bulunamadı.", itemName))
+	if allTimeStats.count() == 0 {
+		msg := tgbotapi.NewMessage(chatID, htmlf("❌ <b>%s</b> adında bağış kalemi bulunamadı.", itemName))
 		msg.ParseMode = "HTML"
 		bot.Send(msg)
 		return
 	}
 
-	// 2. Bugünkü toplam
-	var todayStats struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
+	// 2. Bugünkü toplam (para birimi bazında)
+	var todayStats moneyTotals
 	db.NewRaw(`
 		SELECT 
-			COALESCE(SUM((item->>'price')::numeric * (item->>'quantity')::numeric), 0) as total,
-			COALESCE(SUM((item->>'quantity')::numeric), 0)::int as count
-		FROM orders, jsonb_array_elements(items) as item
-		WHERE item->>'item_name' ILIKE ?
+			o.currency,
+			SUM(oi.price * oi.quantity) as total,
+			SUM(oi.quantity)::int as count
+		FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+		WHERE ?
 		AND event_time >= ? AND event_time < ?
-	`, "%"+itemName+"%", startOfDayUTC, endOfDayUTC).Scan(ctx, &todayStats)
+		GROUP BY o.currency
+		ORDER BY count DESC, o.currency
+	`, scope.orders(), itemFilter, startOfDayUTC, endOfDayUTC).Scan(ctx, &todayStats)
 
 	// 3. Tüm zamanlar kaynak dağılımı
 	var allTimeSources []struct {
-		Source string  `bun:"source"`
-		Total  float64 `bun:"total"`
-		Count  int     `bun:"count"`
+		Source   string  `bun:"source"`
+		Currency string  `bun:"currency"`
+		Total    float64 `bun:"total"`
+		Count    int     `bun:"count"`
 	}
 	db.NewRaw(`
-		SELECT 
-			CASE 
-				WHEN o.utm_source IS NOT NULL AND o.utm_source != '' THEN o.utm_source
-				WHEN o.traffic_channel = 'google' THEN 'Google Ads'
-				ELSE 'Doğrudan'
-			END as source,
-			SUM((item->>'price')::numeric * (item->>'quantity')::numeric) as total,
-			SUM((item->>'quantity')::numeric)::int as count
-		FROM orders o, jsonb_array_elements(o.items) as item
-		WHERE item->>'item_name' ILIKE ?
-		GROUP BY 1
-		ORDER BY total DESC
-	`, "%"+itemName+"%").Scan(ctx, &allTimeSources)
+		SELECT * FROM (
+			SELECT 
+				CASE 
+					WHEN o.utm_source_id IS NOT NULL THEN o.utm_source
+					WHEN o.traffic_channel = 'google' THEN 'Google Ads'
+					ELSE 'Doğrudan'
+				END as source,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			WHERE ?
+			GROUP BY o.utm_source_id, 1, 2
+		) AS sources
+		ORDER BY ?, total DESC
+	`, scope.orders(), itemFilter, bun.Safe(currencyGroupOrder)).Scan(ctx, &allTimeSources)
 
 	// 4. Bugünkü kaynak dağılımı
 	var todaySources []struct {
-		Source string  `bun:"source"`
-		Total  float64 `bun:"total"`
-		Count  int     `bun:"count"`
+		Source   string  `bun:"source"`
+		Currency string  `bun:"currency"`
+		Total    float64 `bun:"total"`
+		Count    int     `bun:"count"`
 	}
 	db.NewRaw(`
-		SELECT 
-			CASE 
-				WHEN o.utm_source IS NOT NULL AND o.utm_source != '' THEN o.utm_source
-				WHEN o.traffic_channel = 'google' THEN 'Google Ads'
-				ELSE 'Doğrudan'
-			END as source,
-			SUM((item->>'price')::numeric * (item->>'quantity')::numeric) as total,
-			SUM((item->>'quantity')::numeric)::int as count
-		FROM orders o, jsonb_array_elements(o.items) as item
-		WHERE item->>'item_name' ILIKE ?
-		AND o.event_time >= ? AND o.event_time < ?
-		GROUP BY 1
-		ORDER BY total DESC
-	`, "%"+itemName+"%", startOfDayUTC, endOfDayUTC).Scan(ctx, &todaySources)
+		SELECT * FROM (
+			SELECT 
+				CASE 
+					WHEN o.utm_source_id IS NOT NULL THEN o.utm_source
+					WHEN o.traffic_channel = 'google' THEN 'Google Ads'
+					ELSE 'Doğrudan'
+				END as source,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			WHERE ?
+			AND o.event_time >= ? AND o.event_time < ?
+			GROUP BY o.utm_source_id, 1, 2
+		) AS sources
+		ORDER BY ?, total DESC
+	`, scope.orders(), itemFilter, startOfDayUTC, endOfDayUTC, bun.Safe(currencyGroupOrder)).Scan(ctx, &todaySources)
 
 	// Raporu oluştur
 	gunAdi := getTurkishDayName(now.Weekday())
 
 	var sb strings.Builder
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
-	sb.WriteString(fmt.Sprintf("📦 <b>%s</b>\n", strings.ToUpper(itemName)))
+	sb.WriteString(htmlf("📦 <b>%s</b>\n", strings.ToUpper(itemName)))
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
 	// Tüm zamanlar
 	sb.WriteString("📊 <b>TÜM ZAMANLAR</b>\n")
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-	sb.WriteString(fmt.Sprintf("   💵 Toplam Tutar : <b>%.2f TRY</b>\n", allTimeStats.Total))
-	sb.WriteString(fmt.Sprintf("   📦 Toplam Adet  : <b>%d</b>\n\n", allTimeStats.Count))
+	sb.WriteString(htmlf("   💵 Toplam Tutar : <b>%s</b>\n", allTimeStats.String()))
+	sb.WriteString(htmlf("   📦 Toplam Adet  : <b>%d</b>\n\n", allTimeStats.count()))
 
 	if len(allTimeSources) > 0 {
 		sb.WriteString("   <b>Kaynak Dağılımı:</b>\n")
 		for _, s := range allTimeSources {
-			percentage := (s.Total / allTimeStats.Total) * 100
-			sb.WriteString(fmt.Sprintf("   • %s: %.2f TRY (%d) %%%.1f\n", s.Source, s.Total, s.Count, percentage))
+			sb.WriteString(htmlf("   • %s: %s (%d) %%%.1f\n", s.Source, formatMoney(s.Total, s.Currency), s.Count, allTimeStats.share(s.Currency, s.Total)))
 		}
 	}
 	sb.WriteString("\n")
 
 	// Bugün
-	sb.WriteString(fmt.Sprintf("☀️ <b>BUGÜN</b> (%s, %s)\n", now.Format("02.01.2006"), gunAdi))
+	sb.WriteString(htmlf("☀️ <b>BUGÜN</b> (%s, %s)\n", now.Format("02.01.2006"), gunAdi))
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	if todayStats.Count == 0 {
+	if todayStats.count() == 0 {
 		sb.WriteString("   ℹ️ Bugün bu kalemden bağış yok.\n")
 	} else {
-		sb.WriteString(fmt.Sprintf("   💵 Toplam Tutar : <b>%.2f TRY</b>\n", todayStats.Total))
-		sb.WriteString(fmt.Sprintf("   📦 Toplam Adet  : <b>%d</b>\n\n", todayStats.Count))
+		sb.WriteString(htmlf("   💵 Toplam Tutar : <b>%s</b>\n", todayStats.String()))
+		sb.WriteString(htmlf("   📦 Toplam Adet  : <b>%d</b>\n\n", todayStats.count()))
 
 		if len(todaySources) > 0 {
 			sb.WriteString("   <b>Kaynak Dağılımı:</b>\n")
 			for _, s := range todaySources {
-				percentage := (s.Total / todayStats.Total) * 100
-				sb.WriteString(fmt.Sprintf("   • %s: %.2f TRY (%d) %%%.1f\n", s.Source, s.Total, s.Count, percentage))
+				sb.WriteString(htmlf("   • %s: %s (%d) %%%.1f\n", s.Source, formatMoney(s.Total, s.Currency), s.Count, todayStats.share(s.Currency, s.Total)))
 			}
 		}
 	}
@@ -2067,132 +3002,463 @@ func handleKalemCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	bot.Send(msg)
 }
 
+// Kalem seçicide sayfa başına gösterilen kalem/kategori butonu sayısı
+const kalemPageSize = 10
+
+// Harf klavyesiyle yapılan aramanın en fazla bayt uzunluğu: arama "kalem:s:<arama>" olarak
+// callback verisine gömülür ve Telegram callback verisi en fazla 64 bayt olabilir (Türkçe harfler 2 bayt)
+const kalemSearchMaxBytes = 64 - len("kalem:s:")
+
+// Arama klavyesindeki harflerin sırası; listede olmayan karakterler sona eklenir
+const kalemAlphabet = "abcçdefgğhıijklmnoöprsştuüvyz0123456789"
+
+// kalemEntry seçicide listelenen bağış kalemi ve kategorisi
+type kalemEntry struct {
+	ItemName string `bun:"item_name"`
+	Category string `bun:"category"`
+}
+
+// kalemCategory kategori adı ve içindeki kalem sayısı
+type kalemCategory struct {
+	Name  string
+	Count int
+}
+
+// loadKalemEntries sohbetin kapsamındaki farklı bağış kalemlerini kategorileriyle birlikte döner
+func loadKalemEntries(ctx context.Context, scope DataScope) ([]kalemEntry, error) {
+	var entries []kalemEntry
+	err := db.NewRaw(`
+		SELECT oi.item_name, COALESCE(MAX(NULLIF(oi.category, '')), '') AS category
+		FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+		GROUP BY oi.item_name
+		ORDER BY oi.item_name
+	`, scope.orders()).Scan(ctx, &entries)
+	return entries, err
+}
+
+// kalemCategories kalemleri kategoriye göre sayar; kategorisizler en sona konur
+func kalemCategories(entries []kalemEntry) []kalemCategory {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Category]++
+	}
+
+	categories := make([]kalemCategory, 0, len(counts))
+	for name, count := range counts {
+		categories = append(categories, kalemCategory{Name: name, Count: count})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if (categories[i].Name == "") != (categories[j].Name == "") {
+			return categories[j].Name == ""
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories
+}
+
+// kalemKey kalem/kategori adını callback verisine sığan kısa anahtara çevirir
+func kalemKey(name string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(name)))
+}
+
+// kalemCategoryTitle boş kategoriyi okunabilir başlığa çevirir
+func kalemCategoryTitle(category string) string {
+	if category == "" {
+		return "Kategorisiz"
+	}
+	return category
+}
+
+// kalemButtonLabel uzun kalem adlarını buton genişliğine göre kısaltır
+func kalemButtonLabel(text string) string {
+	runes := []rune(text)
+	if len(runes) > 40 {
+		return string(runes[:39]) + "…"
+	}
+	return text
+}
+
+// kalemPage sayfa numarasını sınırlar; sayfa, toplam sayfa ve dilim aralığını döner
+func kalemPage(total, page int) (int, int, int, int) {
+	pages := max(1, (total+kalemPageSize-1)/kalemPageSize)
+	page = max(0, min(page, pages-1))
+	start := page * kalemPageSize
+	return page, pages, start, min(start+kalemPageSize, total)
+}
+
+// kalemNavRow önceki/sonraki sayfa butonlarını oluşturur (tek sayfada nil)
+func kalemNavRow(prefix string, page, pages int) []tgbotapi.InlineKeyboardButton {
+	if pages <= 1 {
+		return nil
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️", prefix+strconv.Itoa(page-1)))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, pages), "kalem:noop"))
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶️", prefix+strconv.Itoa(page+1)))
+	}
+	return row
+}
+
+// sendKalemView seçici görünümünü yeni mesaj olarak gönderir ya da buton tıklanan mesajı günceller
+func sendKalemView(bot *tgbotapi.BotAPI, chatID int64, messageID int, text string, rows [][]tgbotapi.InlineKeyboardButton) {
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if messageID == 0 {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = "HTML"
+		msg.ReplyMarkup = markup
+		bot.Send(msg)
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	edit.ParseMode = "HTML"
+	if _, err := bot.Send(edit); err != nil {
+		log.Printf("Kalem seçici güncellenemedi: %v", err)
+	}
+}
+
+// showKalemCategories kategori seçim ekranını gösterir
+func showKalemCategories(bot *tgbotapi.BotAPI, chatID int64, messageID int, entries []kalemEntry, page int) {
+	categories := kalemCategories(entries)
+	page, pages, start, end := kalemPage(len(categories), page)
+
+	var sb strings.Builder
+	sb.WriteString("📦 <b>Bağış Kalemleri</b>\n\n")
+	sb.WriteString(htmlf("%d kalem, %d kategori.\n", len(entries), len(categories)))
+	sb.WriteString("Kategori seçin ya da 🔍 ile arayın.\n\n")
+	sb.WriteString("Detay görmek için:\n<code>/kalem [kalem adı]</code>")
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📋 Tümü (%d)", len(entries)), "kalem:l:*:0")),
+	}
+	for _, c := range categories[start:end] {
+		label := fmt.Sprintf("📂 %s (%d)", kalemButtonLabel(kalemCategoryTitle(c.Name)), c.Count)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "kalem:l:"+kalemKey(c.Name)+":0")))
+	}
+	if nav := kalemNavRow("kalem:c:", page, pages); nav != nil {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔍 Ara", "kalem:s:")))
+
+	sendKalemView(bot, chatID, messageID, sb.String(), rows)
+}
+
+// showKalemList bir kategorinin (ya da "*" ile tüm kalemlerin) sayfalı listesini gösterir
+func showKalemList(bot *tgbotapi.BotAPI, chatID int64, messageID int, entries []kalemEntry, categoryKey string, page int) {
+	title := "Tüm Kalemler"
+	var items []kalemEntry
+	for _, e := range entries {
+		if categoryKey == "*" || kalemKey(e.Category) == categoryKey {
+			items = append(items, e)
+		}
+	}
+	if len(items) == 0 {
+		sendKalemView(bot, chatID, messageID, "⚠️ Kategori bulunamadı, liste değişmiş olabilir. /kalem ile yeniden açın.", nil)
+		return
+	}
+	if categoryKey != "*" {
+		title = kalemCategoryTitle(items[0].Category)
+	}
+
+	page, pages, start, end := kalemPage(len(items), page)
+
+	var sb strings.Builder
+	sb.WriteString(htmlf("📦 <b>%s</b>\n\n", title))
+	sb.WriteString(htmlf("%d kalem — sayfa %d/%d\n", len(items), page+1, pages))
+	sb.WriteString("Detay için kaleme dokunun.")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, item := range items[start:end] {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(kalemButtonLabel(item.ItemName), "kalem:d:"+kalemKey(item.ItemName))))
+	}
+	if nav := kalemNavRow(fmt.Sprintf("kalem:l:%s:", categoryKey), page, pages); nav != nil {
+		rows = append(rows, nav)
+	}
+	footer := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔍 Ara", "kalem:s:"))
+	if len(kalemCategories(entries)) > 1 {
+		footer = append(footer, tgbotapi.NewInlineKeyboardButtonData("📂 Kategoriler", "kalem:c:0"))
+	}
+	rows = append(rows, footer)
+
+	sendKalemView(bot, chatID, messageID, sb.String(), rows)
+}
+
+// showKalemSearch harf klavyesiyle aramayı gösterir; her harf aramayı daraltır ve
+// klavyede sadece mevcut eşleşmeleri devam ettiren harfler kalır
+func showKalemSearch(bot *tgbotapi.BotAPI, chatID int64, messageID int, entries []kalemEntry, query string) {
+	var matches []kalemEntry
+	nextRunes := make(map[rune]bool)
+	for _, e := range entries {
+		name := strings.ToLowerSpecial(unicode.TurkishCase, e.ItemName)
+		if !strings.Contains(name, query) {
+			continue
+		}
+		matches = append(matches, e)
+
+		// Aramanın geçtiği her yerden sonraki karakter bir sonraki harf adayıdır
+		for rest := name; ; {
+			i := strings.Index(rest, query)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(query):]
+			r, size := utf8.DecodeRuneInString(rest)
+			if size == 0 {
+				break
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				nextRunes[r] = true
+			}
+			if query == "" {
+				rest = rest[size:]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔍 <b>Kalem Arama</b>\n\n")
+	if query == "" {
+		sb.WriteString("Harflere dokunarak arayın.\n")
+	} else {
+		sb.WriteString(htmlf("Arama: <code>%s</code>\n", query))
+	}
+	sb.WriteString(htmlf("%d eşleşme", len(matches)))
+	if len(matches) > kalemPageSize {
+		sb.WriteString(htmlf(", ilk %d gösteriliyor. Daraltmak için harf ekleyin.", kalemPageSize))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, item := range matches[:min(len(matches), kalemPageSize)] {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(kalemButtonLabel(item.ItemName), "kalem:d:"+kalemKey(item.ItemName))))
+	}
+
+	if len(matches) > 1 {
+		letters := make([]rune, 0, len(nextRunes))
+		for r := range nextRunes {
+			if len(query)+utf8.RuneLen(r) <= kalemSearchMaxBytes {
+				letters = append(letters, r)
+			}
+		}
+		order := func(r rune) int {
+			if i := strings.IndexRune(kalemAlphabet, r); i >= 0 {
+				return i
+			}
+			return len(kalemAlphabet) + int(r)
+		}
+		sort.Slice(letters, func(i, j int) bool { return order(letters[i]) < order(letters[j]) })
+
+		var row []tgbotapi.InlineKeyboardButton
+		for i, r := range letters {
+			label := strings.ToUpperSpecial(unicode.TurkishCase, string(r))
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "kalem:s:"+query+string(r)))
+			if (i+1)%8 == 0 || i == len(letters)-1 {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+	}
+
+	var footer []tgbotapi.InlineKeyboardButton
+	if query != "" {
+		runes := []rune(query)
+		footer = append(footer,
+			tgbotapi.NewInlineKeyboardButtonData("⌫ Sil", "kalem:s:"+string(runes[:len(runes)-1])),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Temizle", "kalem:s:"))
+	}
+	footer = append(footer, tgbotapi.NewInlineKeyboardButtonData("📋 Liste", "kalem:c:0"))
+	rows = append(rows, footer)
+
+	sendKalemView(bot, chatID, messageID, sb.String(), rows)
+}
+
+// handleKalemCallback kalem seçicideki buton tıklamalarını işler.
+// Callback verisi: kalem:c:<sayfa> | kalem:l:<kategori>:<sayfa> | kalem:s:<arama> | kalem:d:<kalem>
+func handleKalemCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	userID := callback.From.ID
+	messageID := callback.Message.MessageID
+	parts := strings.SplitN(strings.TrimPrefix(callback.Data, "kalem:"), ":", 3)
+	if parts[0] == "noop" || len(parts) < 2 {
+		return
+	}
+
+	ctx := context.Background()
+	entries, err := loadKalemEntries(ctx, getDataScope(ctx, chatID, userID))
+	if err != nil {
+		log.Printf("Kalem listesi sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	switch parts[0] {
+	case "c":
+		page, _ := strconv.Atoi(parts[1])
+		if len(kalemCategories(entries)) > 1 {
+			showKalemCategories(bot, chatID, messageID, entries, page)
+		} else {
+			showKalemList(bot, chatID, messageID, entries, "*", 0)
+		}
+	case "l":
+		page := 0
+		if len(parts) > 2 {
+			page, _ = strconv.Atoi(parts[2])
+		}
+		showKalemList(bot, chatID, messageID, entries, parts[1], page)
+	case "s":
+		showKalemSearch(bot, chatID, messageID, entries, parts[1])
+	case "d":
+		for _, e := range entries {
+			if kalemKey(e.ItemName) == parts[1] {
+				sendKalemReport(bot, chatID, userID, e.ItemName, true)
+				return
+			}
+		}
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Kalem bulunamadı, liste değişmiş olabilir. /kalem ile yeniden açın.")
+		bot.Send(msg)
+	}
+}
+
 // handleSourceAnalysisCommand /google ve /meta komutlarını işler - Kaynak bazlı detaylı analiz
-func handleSourceAnalysisCommand(bot *tgbotapi.BotAPI, chatID int64, source string) {
+func handleSourceAnalysisCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, source string) {
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
+
+	stopProgress := startProgress(bot, chatID, tgbotapi.ChatTyping, "Kaynak analizi")
+	defer stopProgress()
 
 	// Türkiye saatine göre bugünün UTC aralığını al
 	startOfDayUTC, endOfDayUTC, now := getDayRangeUTC(0)
 
 	// Kaynak filtresi oluştur
-	var sourceFilter string
+	sourceOrders := scope.orders()
 	var sourceTitle string
 	var sourceEmoji string
 
 	switch source {
 	case "google":
-		sourceFilter = "(utm_source = 'google' OR traffic_channel = 'google')"
+		sourceOrders = sourceOrders.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where(dimensionIDFilter("utm_source"), "google").WhereOr("traffic_channel = 'google'")
+		})
 		sourceTitle = "GOOGLE ADS"
 		sourceEmoji = "🔍"
 	case "meta":
-		sourceFilter = "utm_source = 'meta'"
+		sourceOrders = sourceOrders.Where(dimensionIDFilter("utm_source"), "meta")
 		sourceTitle = "META (Facebook/Instagram)"
 		sourceEmoji = "📱"
 	default:
-		sourceFilter = fmt.Sprintf("utm_source = '%s'", source)
+		sourceOrders = sourceOrders.Where(dimensionIDFilter("utm_source"), source)
 		sourceTitle = strings.ToUpper(source)
 		sourceEmoji = "📊"
 	}
 
-	// 1. Tüm zamanlar - Toplam
-	var allTimeTotal struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
-	db.NewRaw(fmt.Sprintf(`
-		SELECT COALESCE(SUM(amount), 0) as total, COUNT(*) as count
-		FROM orders WHERE %s
-	`, sourceFilter)).Scan(ctx, &allTimeTotal)
+	// 1. Tüm zamanlar - Toplam (para birimi bazında)
+	var allTimeTotal moneyTotals
+	db.NewRaw(`
+		SELECT currency, SUM(amount) as total, COUNT(*) as count
+		FROM (?) AS orders
+		GROUP BY currency
+		ORDER BY count DESC, currency
+	`, sourceOrders).Scan(ctx, &allTimeTotal)
 
 	// 2. Tüm zamanlar - Bağış kalemleri
 	var allTimeItems []struct {
 		ItemName string  `bun:"item_name"`
+		Currency string  `bun:"currency"`
 		Total    float64 `bun:"total"`
 		Count    int     `bun:"count"`
 	}
-	db.NewRaw(fmt.Sprintf(`
-		SELECT 
-			item->>'item_name' as item_name,
-			SUM((item->>'price')::numeric * (item->>'quantity')::numeric) as total,
-			SUM((item->>'quantity')::numeric)::int as count
-		FROM orders o, jsonb_array_elements(o.items) as item
-		WHERE %s
-		GROUP BY item->>'item_name'
-		ORDER BY total DESC
-	`, sourceFilter)).Scan(ctx, &allTimeItems)
-
-	// 3. Bugün - Toplam
-	var todayTotal struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
-	db.NewRaw(fmt.Sprintf(`
-		SELECT COALESCE(SUM(amount), 0) as total, COUNT(*) as count
-		FROM orders WHERE %s AND event_time >= ? AND event_time < ?
-	`, sourceFilter), startOfDayUTC, endOfDayUTC).Scan(ctx, &todayTotal)
+	db.NewRaw(`
+		SELECT * FROM (
+			SELECT 
+				oi.item_name,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			GROUP BY oi.item_name, o.currency
+		) AS items
+		ORDER BY ?, total DESC
+	`, sourceOrders, bun.Safe(currencyGroupOrder)).Scan(ctx, &allTimeItems)
+
+	// 3. Bugün - Toplam (para birimi bazında)
+	var todayTotal moneyTotals
+	db.NewRaw(`
+		SELECT currency, SUM(amount) as total, COUNT(*) as count
+		FROM (?) AS orders WHERE event_time >= ? AND event_time < ?
+		GROUP BY currency
+		ORDER BY count DESC, currency
+	`, sourceOrders, startOfDayUTC, endOfDayUTC).Scan(ctx, &todayTotal)
 
 	// 4. Bugün - Bağış kalemleri
 	var todayItems []struct {
 		ItemName string  `bun:"item_name"`
+		Currency string  `bun:"currency"`
 		Total    float64 `bun:"total"`
 		Count    int     `bun:"count"`
 	}
-	db.NewRaw(fmt.Sprintf(`
-		SELECT 
-			item->>'item_name' as item_name,
-			SUM((item->>'price')::numeric * (item->>'quantity')::numeric) as total,
-			SUM((item->>'quantity')::numeric)::int as count
-		FROM orders o, jsonb_array_elements(o.items) as item
-		WHERE %s AND o.event_time >= ? AND o.event_time < ?
-		GROUP BY item->>'item_name'
-		ORDER BY total DESC
-	`, sourceFilter), startOfDayUTC, endOfDayUTC).Scan(ctx, &todayItems)
+	db.NewRaw(`
+		SELECT * FROM (
+			SELECT 
+				oi.item_name,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			WHERE o.event_time >= ? AND o.event_time < ?
+			GROUP BY oi.item_name, o.currency
+		) AS items
+		ORDER BY ?, total DESC
+	`, sourceOrders, startOfDayUTC, endOfDayUTC, bun.Safe(currencyGroupOrder)).Scan(ctx, &todayItems)
 
 	// Raporu oluştur
 	gunAdi := getTurkishDayName(now.Weekday())
 
 	var sb strings.Builder
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
-	sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", sourceEmoji, sourceTitle))
+	sb.WriteString(htmlf("%s <b>%s</b>\n", sourceEmoji, sourceTitle))
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
 	// Tüm zamanlar
 	sb.WriteString("📊 <b>TÜM ZAMANLAR</b>\n")
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	if allTimeTotal.Count == 0 {
+	if allTimeTotal.count() == 0 {
 		sb.WriteString("   ℹ️ Bu kaynaktan bağış bulunmuyor.\n\n")
 	} else {
-		sb.WriteString(fmt.Sprintf("   💵 Toplam Gelir  : <b>%.2f TRY</b>\n", allTimeTotal.Total))
-		sb.WriteString(fmt.Sprintf("   🛒 Bağış Sayısı  : <b>%d</b>\n", allTimeTotal.Count))
-		sb.WriteString(fmt.Sprintf("   📊 Ortalama      : <b>%.2f TRY</b>\n\n", allTimeTotal.Total/float64(allTimeTotal.Count)))
+		sb.WriteString(htmlf("   💵 Toplam Gelir  : <b>%s</b>\n", allTimeTotal.String()))
+		sb.WriteString(htmlf("   🛒 Bağış Sayısı  : <b>%d</b>\n", allTimeTotal.count()))
+		sb.WriteString(htmlf("   📊 Ortalama      : <b>%s</b>\n\n", allTimeTotal.averages()))
 
 		if len(allTimeItems) > 0 {
 			sb.WriteString("   <b>📦 Bağış Kalemleri:</b>\n")
 			for _, item := range allTimeItems {
-				sb.WriteString(fmt.Sprintf("   • %s\n", item.ItemName))
-				sb.WriteString(fmt.Sprintf("     └ %.2f TRY | %d adet\n", item.Total, item.Count))
+				sb.WriteString(htmlf("   • %s\n", item.ItemName))
+				sb.WriteString(htmlf("     └ %s | %d adet\n", formatMoney(item.Total, item.Currency), item.Count))
 			}
 		}
 	}
 	sb.WriteString("\n")
 
 	// Bugün
-	sb.WriteString(fmt.Sprintf("☀️ <b>BUGÜN</b> (%s, %s)\n", now.Format("02.01.2006"), gunAdi))
+	sb.WriteString(htmlf("☀️ <b>BUGÜN</b> (%s, %s)\n", now.Format("02.01.2006"), gunAdi))
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	if todayTotal.Count == 0 {
+	if todayTotal.count() == 0 {
 		sb.WriteString("   ℹ️ Bugün bu kaynaktan bağış yok.\n")
 	} else {
-		sb.WriteString(fmt.Sprintf("   💵 Toplam Gelir  : <b>%.2f TRY</b>\n", todayTotal.Total))
-		sb.WriteString(fmt.Sprintf("   🛒 Bağış Sayısı  : <b>%d</b>\n", todayTotal.Count))
-		sb.WriteString(fmt.Sprintf("   📊 Ortalama      : <b>%.2f TRY</b>\n\n", todayTotal.Total/float64(todayTotal.Count)))
+		sb.WriteString(htmlf("   💵 Toplam Gelir  : <b>%s</b>\n", todayTotal.String()))
+		sb.WriteString(htmlf("   🛒 Bağış Sayısı  : <b>%d</b>\n", todayTotal.count()))
+		sb.WriteString(htmlf("   📊 Ortalama      : <b>%s</b>\n\n", todayTotal.averages()))
 
 		if len(todayItems) > 0 {
 			sb.WriteString("   <b>📦 Bağış Kalemleri:</b>\n")
 			for _, item := range todayItems {
-				sb.WriteString(fmt.Sprintf("   • %s\n", item.ItemName))
-				sb.WriteString(fmt.Sprintf("     └ %.2f TRY | %d adet\n", item.Total, item.Count))
+				sb.WriteString(htmlf("   • %s\n", item.ItemName))
+				sb.WriteString(htmlf("     └ %s | %d adet\n", formatMoney(item.Total, item.Currency), item.Count))
 			}
 		}
 	}
@@ -2205,33 +3471,34 @@ func handleSourceAnalysisCommand(bot *tgbotapi.BotAPI, chatID int64, source stri
 }
 
 // handleBugunCommand /bugun komutunu işler - Bugünün bağışları (kalem kalem + toplam)
-func handleBugunCommand(bot *tgbotapi.BotAPI, chatID int64) {
-	handleDayReport(bot, chatID, 0)
+func handleBugunCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	handleDayReport(bot, chatID, userID, 0)
 }
 
 // handleDunCommand /dun komutunu işler - Dünün bağışları
-func handleDunCommand(bot *tgbotapi.BotAPI, chatID int64) {
-	handleDayReport(bot, chatID, -1)
+func handleDunCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	handleDayReport(bot, chatID, userID, -1)
 }
 
 // handleDayReport belirli bir günün raporunu oluşturur (dayOffset: 0=bugün, -1=dün)
-func handleDayReport(bot *tgbotapi.BotAPI, chatID int64, dayOffset int) {
+func handleDayReport(bot *tgbotapi.BotAPI, chatID int64, userID int64, dayOffset int) {
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
 
 	// Türkiye saatine göre günün UTC aralığını al
 	startOfDayUTC, endOfDayUTC, targetDay := getDayRangeUTC(dayOffset)
 
-	// Genel istatistikler
-	var stats struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
+	// Genel istatistikler (para birimi bazında)
+	var stats moneyTotals
 	err := db.NewSelect().
-		TableExpr("orders").
-		ColumnExpr("COALESCE(SUM(amount), 0) as total").
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
+		ColumnExpr("SUM(amount) as total").
 		ColumnExpr("COUNT(*) as count").
 		Where("event_time >= ?", startOfDayUTC).
 		Where("event_time < ?", endOfDayUTC).
+		GroupExpr("currency").
+		OrderExpr("count DESC, currency").
 		Scan(ctx, &stats)
 
 	if err != nil {
@@ -2244,40 +3511,48 @@ func handleDayReport(bot *tgbotapi.BotAPI, chatID int64, dayOffset int) {
 	// Bağış kalemleri
 	var items []struct {
 		ItemName string  `bun:"item_name"`
+		Currency string  `bun:"currency"`
 		Total    float64 `bun:"total"`
 		Count    int     `bun:"count"`
 	}
 	db.NewRaw(`
-		SELECT 
-			item->>'item_name' as item_name,
-			SUM((item->>'price')::numeric * (item->>'quantity')::numeric) as total,
-			SUM((item->>'quantity')::numeric)::int as count
-		FROM orders o, jsonb_array_elements(o.items) as item
-		WHERE o.event_time >= ? AND o.event_time < ?
-		GROUP BY item->>'item_name'
-		ORDER BY total DESC
-	`, startOfDayUTC, endOfDayUTC).Scan(ctx, &items)
+		SELECT * FROM (
+			SELECT 
+				oi.item_name,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			WHERE o.event_time >= ? AND o.event_time < ?
+			GROUP BY oi.item_name, o.currency
+		) AS items
+		ORDER BY ?, total DESC
+	`, scope.orders(), startOfDayUTC, endOfDayUTC, bun.Safe(currencyGroupOrder)).Scan(ctx, &items)
 
 	// Kaynak dağılımı
 	var sources []struct {
-		Source string  `bun:"source"`
-		Total  float64 `bun:"total"`
-		Count  int     `bun:"count"`
+		Source   string  `bun:"source"`
+		Currency string  `bun:"currency"`
+		Total    float64 `bun:"total"`
+		Count    int     `bun:"count"`
 	}
 	db.NewRaw(`
-		SELECT 
-			CASE 
-				WHEN utm_source IS NOT NULL AND utm_source != '' THEN utm_source
-				WHEN traffic_channel = 'google' THEN 'Google Ads'
-				ELSE 'Doğrudan'
-			END as source,
-			SUM(amount) as total,
-			COUNT(*) as count
-		FROM orders
-		WHERE event_time >= ? AND event_time < ?
-		GROUP BY 1
-		ORDER BY total DESC
-	`, startOfDayUTC, endOfDayUTC).Scan(ctx, &sources)
+		SELECT * FROM (
+			SELECT 
+				CASE 
+					WHEN utm_source_id IS NOT NULL THEN utm_source
+					WHEN traffic_channel = 'google' THEN 'Google Ads'
+					ELSE 'Doğrudan'
+				END as source,
+				currency,
+				SUM(amount) as total,
+				COUNT(*) as count
+			FROM (?) AS orders
+			WHERE event_time >= ? AND event_time < ?
+			GROUP BY utm_source_id, 1, 2
+		) AS sources
+		ORDER BY ?, total DESC
+	`, scope.orders(), startOfDayUTC, endOfDayUTC, bun.Safe(currencyGroupOrder)).Scan(ctx, &sources)
 
 	// Rapor başlığı
 	gunAdi := getTurkishDayName(targetDay.Weekday())
@@ -2290,31 +3565,31 @@ func handleDayReport(bot *tgbotapi.BotAPI, chatID int64, dayOffset int) {
 
 	var sb strings.Builder
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
-	sb.WriteString(fmt.Sprintf("<b>%s</b>\n", title))
+	sb.WriteString(htmlf("<b>%s</b>\n", title))
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-	sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s, %s\n\n", targetDay.Format("02 Ocak 2006"), gunAdi))
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s, %s\n\n", formatTurkishDate(targetDay), gunAdi))
 
-	if stats.Count == 0 {
+	if stats.count() == 0 {
 		sb.WriteString("ℹ️ Bu tarihte bağış bulunmamaktadır.\n")
 	} else {
 		// Genel özet
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 		sb.WriteString("💰 <b>GENEL ÖZET</b>\n")
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-		sb.WriteString(fmt.Sprintf("   🛒 Bağış Sayısı  : <b>%d</b>\n", stats.Count))
-		sb.WriteString(fmt.Sprintf("   💵 Toplam Tutar  : <b>%.2f TRY</b>\n", stats.Total))
-		sb.WriteString(fmt.Sprintf("   📊 Ortalama      : <b>%.2f TRY</b>\n\n", stats.Total/float64(stats.Count)))
+		sb.WriteString(htmlf("   🛒 Bağış Sayısı  : <b>%d</b>\n", stats.count()))
+		sb.WriteString(htmlf("   💵 Toplam Tutar  : <b>%s</b>\n", stats.String()))
+		sb.WriteString(htmlf("   📊 Ortalama      : <b>%s</b>\n\n", stats.averages()))
 
-		// Bağış kalemleri
+		// Bağış kalemleri (yüzdeler kendi para birimindeki toplama göre)
 		if len(items) > 0 {
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 			sb.WriteString("📦 <b>BAĞIŞ KALEMLERİ</b>\n")
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-			for i, item := range items {
-				emoji := getEmojiByRank(i)
-				percentage := (item.Total / stats.Total) * 100
-				sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, item.ItemName))
-				sb.WriteString(fmt.Sprintf("   └ %.2f TRY | %d adet | %%%.1f\n\n", item.Total, item.Count, percentage))
+			sections := currencySections{multiple: len(stats) > 1}
+			for _, item := range items {
+				emoji := getEmojiByRank(sections.next(&sb, item.Currency))
+				sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, item.ItemName))
+				sb.WriteString(htmlf("   └ %s | %d adet | %%%.1f\n\n", formatMoney(item.Total, item.Currency), item.Count, stats.share(item.Currency, item.Total)))
 			}
 		}
 
@@ -2323,10 +3598,11 @@ func handleDayReport(bot *tgbotapi.BotAPI, chatID int64, dayOffset int) {
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 			sb.WriteString("📡 <b>KAYNAK DAĞILIMI</b>\n")
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
+			sections := currencySections{multiple: len(stats) > 1}
 			for _, s := range sources {
-				percentage := (s.Total / stats.Total) * 100
-				sb.WriteString(fmt.Sprintf("   • <b>%s</b>\n", s.Source))
-				sb.WriteString(fmt.Sprintf("     └ %.2f TRY | %d bağış | %%%.1f\n\n", s.Total, s.Count, percentage))
+				sections.next(&sb, s.Currency)
+				sb.WriteString(htmlf("   • <b>%s</b>\n", s.Source))
+				sb.WriteString(htmlf("     └ %s | %d bağış | %%%.1f\n\n", formatMoney(s.Total, s.Currency), s.Count, stats.share(s.Currency, s.Total)))
 			}
 		}
 	}
@@ -2339,19 +3615,19 @@ func handleDayReport(bot *tgbotapi.BotAPI, chatID int64, dayOffset int) {
 }
 
 // handleSMSBugunCommand /sms-bugun komutunu işler
-func handleSMSBugunCommand(bot *tgbotapi.BotAPI, chatID int64) {
+func handleSMSBugunCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
 	startUTC, endUTC, targetDay := getDayRangeUTC(0)
-	handleSourceDayReportWithRange(bot, chatID, "sms", startUTC, endUTC, targetDay)
+	handleSourceDayReportWithRange(bot, chatID, userID, "sms", startUTC, endUTC, targetDay)
 }
 
 // handleMailBugunCommand /mail-bugun komutunu işler
-func handleMailBugunCommand(bot *tgbotapi.BotAPI, chatID int64) {
+func handleMailBugunCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
 	startUTC, endUTC, targetDay := getDayRangeUTC(0)
-	handleSourceDayReportWithRange(bot, chatID, "email", startUTC, endUTC, targetDay)
+	handleSourceDayReportWithRange(bot, chatID, userID, "email", startUTC, endUTC, targetDay)
 }
 
 // handleSMSCommand /sms tarih komutunu işler
-func handleSMSCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleSMSCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	args = strings.TrimSpace(args)
 	if args == "" {
 		msg := tgbotapi.NewMessage(chatID, "⚠️ Lütfen tarih belirtin.\n\nKullanım: <code>/sms DD.MM.YYYY</code>\n\nÖrnek: <code>/sms 15.02.2026</code>")
@@ -2372,11 +3648,11 @@ func handleSMSCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	// Günün başlangıç ve bitiş zamanlarını hesapla
 	startOfDayTR := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, turkeyLoc)
 	endOfDayTR := startOfDayTR.AddDate(0, 0, 1)
-	handleSourceDayReportWithRange(bot, chatID, "sms", startOfDayTR.UTC(), endOfDayTR.UTC(), targetDate)
+	handleSourceDayReportWithRange(bot, chatID, userID, "sms", startOfDayTR.UTC(), endOfDayTR.UTC(), targetDate)
 }
 
 // handleMailCommand /mail tarih komutunu işler
-func handleMailCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
+func handleMailCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
 	args = strings.TrimSpace(args)
 	if args == "" {
 		msg := tgbotapi.NewMessage(chatID, "⚠️ Lütfen tarih belirtin.\n\nKullanım: <code>/mail DD.MM.YYYY</code>\n\nÖrnek: <code>/mail 15.02.2026</code>")
@@ -2397,43 +3673,47 @@ func handleMailCommand(bot *tgbotapi.BotAPI, chatID int64, args string) {
 	// Günün başlangıç ve bitiş zamanlarını hesapla
 	startOfDayTR := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, turkeyLoc)
 	endOfDayTR := startOfDayTR.AddDate(0, 0, 1)
-	handleSourceDayReportWithRange(bot, chatID, "email", startOfDayTR.UTC(), endOfDayTR.UTC(), targetDate)
+	handleSourceDayReportWithRange(bot, chatID, userID, "email", startOfDayTR.UTC(), endOfDayTR.UTC(), targetDate)
 }
 
 // handleSourceDayReportWithRange belirli bir kaynak ve UTC zaman aralığı için rapor oluşturur
-func handleSourceDayReportWithRange(bot *tgbotapi.BotAPI, chatID int64, source string, startOfDayUTC, endOfDayUTC, targetDate time.Time) {
+func handleSourceDayReportWithRange(bot *tgbotapi.BotAPI, chatID int64, userID int64, source string, startOfDayUTC, endOfDayUTC, targetDate time.Time) {
 	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
 
 	// Kaynak filtresi
-	var sourceFilter string
+	sourceOrders := scope.orders()
 	var sourceTitle string
 	var sourceEmoji string
 
 	switch source {
 	case "sms":
-		sourceFilter = "(utm_source = 'sms' OR utm_medium = 'sms')"
+		sourceOrders = sourceOrders.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where(dimensionIDFilter("utm_source"), "sms").WhereOr(dimensionIDFilter("utm_medium"), "sms")
+		})
 		sourceTitle = "SMS"
 		sourceEmoji = "💬"
 	case "email":
-		sourceFilter = "(utm_source = 'email' OR utm_medium = 'email')"
+		sourceOrders = sourceOrders.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where(dimensionIDFilter("utm_source"), "email").WhereOr(dimensionIDFilter("utm_medium"), "email")
+		})
 		sourceTitle = "E-POSTA"
 		sourceEmoji = "📧"
 	default:
-		sourceFilter = fmt.Sprintf("utm_source = '%s'", source)
+		sourceOrders = sourceOrders.Where(dimensionIDFilter("utm_source"), source)
 		sourceTitle = strings.ToUpper(source)
 		sourceEmoji = "📊"
 	}
 
-	// Genel istatistikler
-	var stats struct {
-		Total float64 `bun:"total"`
-		Count int     `bun:"count"`
-	}
-	err := db.NewRaw(fmt.Sprintf(`
-		SELECT COALESCE(SUM(amount), 0) as total, COUNT(*) as count
-		FROM orders
-		WHERE %s AND event_time >= ? AND event_time < ?
-	`, sourceFilter), startOfDayUTC, endOfDayUTC).Scan(ctx, &stats)
+	// Genel istatistikler (para birimi bazında)
+	var stats moneyTotals
+	err := db.NewRaw(`
+		SELECT currency, SUM(amount) as total, COUNT(*) as count
+		FROM (?) AS orders
+		WHERE event_time >= ? AND event_time < ?
+		GROUP BY currency
+		ORDER BY count DESC, currency
+	`, sourceOrders, startOfDayUTC, endOfDayUTC).Scan(ctx, &stats)
 
 	if err != nil {
 		log.Printf("Kaynak rapor sorgu hatası: %v", err)
@@ -2445,67 +3725,75 @@ func handleSourceDayReportWithRange(bot *tgbotapi.BotAPI, chatID int64, source s
 	// Bağış kalemleri
 	var items []struct {
 		ItemName string  `bun:"item_name"`
+		Currency string  `bun:"currency"`
 		Total    float64 `bun:"total"`
 		Count    int     `bun:"count"`
 	}
-	db.NewRaw(fmt.Sprintf(`
-		SELECT 
-			item->>'item_name' as item_name,
-			SUM((item->>'price')::numeric * (item->>'quantity')::numeric) as total,
-			SUM((item->>'quantity')::numeric)::int as count
-		FROM orders o, jsonb_array_elements(o.items) as item
-		WHERE %s AND o.event_time >= ? AND o.event_time < ?
-		GROUP BY item->>'item_name'
-		ORDER BY total DESC
-	`, sourceFilter), startOfDayUTC, endOfDayUTC).Scan(ctx, &items)
+	db.NewRaw(`
+		SELECT * FROM (
+			SELECT 
+				oi.item_name,
+				o.currency,
+				SUM(oi.price * oi.quantity) as total,
+				SUM(oi.quantity)::int as count
+			FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+			WHERE o.event_time >= ? AND o.event_time < ?
+			GROUP BY oi.item_name, o.currency
+		) AS items
+		ORDER BY ?, total DESC
+	`, sourceOrders, startOfDayUTC, endOfDayUTC, bun.Safe(currencyGroupOrder)).Scan(ctx, &items)
 
 	// Kampanya bazlı dağılım
 	var campaigns []struct {
 		Campaign string  `bun:"campaign"`
+		Currency string  `bun:"currency"`
 		Total    float64 `bun:"total"`
 		Count    int     `bun:"count"`
 	}
-	db.NewRaw(fmt.Sprintf(`
-		SELECT 
-			COALESCE(utm_campaign, 'Belirtilmemiş') as campaign,
-			SUM(amount) as total,
-			COUNT(*) as count
-		FROM orders
-		WHERE %s AND event_time >= ? AND event_time < ?
-		GROUP BY utm_campaign
-		ORDER BY total DESC
-	`, sourceFilter), startOfDayUTC, endOfDayUTC).Scan(ctx, &campaigns)
+	db.NewRaw(`
+		SELECT * FROM (
+			SELECT 
+				COALESCE(utm_campaign, 'Belirtilmemiş') as campaign,
+				currency,
+				SUM(amount) as total,
+				COUNT(*) as count
+			FROM (?) AS orders
+			WHERE event_time >= ? AND event_time < ?
+			GROUP BY utm_campaign_id, utm_campaign, currency
+		) AS campaigns
+		ORDER BY ?, total DESC
+	`, sourceOrders, startOfDayUTC, endOfDayUTC, bun.Safe(currencyGroupOrder)).Scan(ctx, &campaigns)
 
 	// Rapor oluştur
 	gunAdi := getTurkishDayName(targetDate.Weekday())
 
 	var sb strings.Builder
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
-	sb.WriteString(fmt.Sprintf("%s <b>%s RAPORU</b>\n", sourceEmoji, sourceTitle))
+	sb.WriteString(htmlf("%s <b>%s RAPORU</b>\n", sourceEmoji, sourceTitle))
 	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-	sb.WriteString(fmt.Sprintf("📅 <b>Tarih:</b> %s, %s\n\n", targetDate.Format("02 Ocak 2006"), gunAdi))
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s, %s\n\n", formatTurkishDate(targetDate), gunAdi))
 
-	if stats.Count == 0 {
-		sb.WriteString(fmt.Sprintf("ℹ️ Bu tarihte %s kaynaklı bağış bulunmamaktadır.\n", sourceTitle))
+	if stats.count() == 0 {
+		sb.WriteString(htmlf("ℹ️ Bu tarihte %s kaynaklı bağış bulunmamaktadır.\n", sourceTitle))
 	} else {
 		// Genel özet
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 		sb.WriteString("💰 <b>GENEL ÖZET</b>\n")
 		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-		sb.WriteString(fmt.Sprintf("   🛒 Bağış Sayısı  : <b>%d</b>\n", stats.Count))
-		sb.WriteString(fmt.Sprintf("   💵 Toplam Tutar  : <b>%.2f TRY</b>\n", stats.Total))
-		sb.WriteString(fmt.Sprintf("   📊 Ortalama      : <b>%.2f TRY</b>\n\n", stats.Total/float64(stats.Count)))
+		sb.WriteString(htmlf("   🛒 Bağış Sayısı  : <b>%d</b>\n", stats.count()))
+		sb.WriteString(htmlf("   💵 Toplam Tutar  : <b>%s</b>\n", stats.String()))
+		sb.WriteString(htmlf("   📊 Ortalama      : <b>%s</b>\n\n", stats.averages()))
 
-		// Bağış kalemleri
+		// Bağış kalemleri (yüzdeler kendi para birimindeki toplama göre)
 		if len(items) > 0 {
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 			sb.WriteString("📦 <b>BAĞIŞ KALEMLERİ</b>\n")
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
-			for i, item := range items {
-				emoji := getEmojiByRank(i)
-				percentage := (item.Total / stats.Total) * 100
-				sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", emoji, item.ItemName))
-				sb.WriteString(fmt.Sprintf("   └ %.2f TRY | %d adet | %%%.1f\n\n", item.Total, item.Count, percentage))
+			sections := currencySections{multiple: len(stats) > 1}
+			for _, item := range items {
+				emoji := getEmojiByRank(sections.next(&sb, item.Currency))
+				sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, item.ItemName))
+				sb.WriteString(htmlf("   └ %s | %d adet | %%%.1f\n\n", formatMoney(item.Total, item.Currency), item.Count, stats.share(item.Currency, item.Total)))
 			}
 		}
 
@@ -2514,10 +3802,11 @@ func handleSourceDayReportWithRange(bot *tgbotapi.BotAPI, chatID int64, source s
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
 			sb.WriteString("🎯 <b>KAMPANYA DAĞILIMI</b>\n")
 			sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n\n")
+			sections := currencySections{multiple: len(stats) > 1}
 			for _, c := range campaigns {
-				percentage := (c.Total / stats.Total) * 100
-				sb.WriteString(fmt.Sprintf("   • <b>%s</b>\n", c.Campaign))
-				sb.WriteString(fmt.Sprintf("     └ %.2f TRY | %d bağış | %%%.1f\n\n", c.Total, c.Count, percentage))
+				sections.next(&sb, c.Currency)
+				sb.WriteString(htmlf("   • <b>%s</b>\n", c.Campaign))
+				sb.WriteString(htmlf("     └ %s | %d bağış | %%%.1f\n\n", formatMoney(c.Total, c.Currency), c.Count, stats.share(c.Currency, c.Total)))
 			}
 		}
 	}
@@ -2529,6 +3818,101 @@ func handleSourceDayReportWithRange(bot *tgbotapi.BotAPI, chatID int64, source s
 	bot.Send(msg)
 }
 
+// writeTagGroupsToSheet etiket gruplarını ve kaynak kırılımlarını sayfaya yazar
+func writeTagGroupsToSheet(f *excelize.File, sheetName string, title string, groups []tagGroup, headerStyle, groupStyle int) {
+	f.NewSheet(sheetName)
+
+	headers := []string{title, "Kaynak", "Bağış Sayısı", "Toplam Tutar"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, h)
+	}
+	f.SetCellStyle(sheetName, "A1", "D1", headerStyle)
+
+	row := 2
+	for _, g := range groups {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), g.Name)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), "TOPLAM")
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), g.Count)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), formatMoney(g.Total, g.Currency))
+		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), groupStyle)
+		row++
+		for _, r := range g.Rows {
+			f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), g.Name)
+			f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), r.Name)
+			f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), r.Count)
+			f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), formatMoney(r.Total, g.Currency))
+			row++
+		}
+	}
+
+	f.SetColWidth(sheetName, "A", "B", 25)
+	f.SetColWidth(sheetName, "C", "D", 15)
+}
+
+// createExportStyles Excel export'larında kullanılan başlık, veri, tutar ve yüzde stillerini oluşturur
+func createExportStyles(f *excelize.File) (headerStyle, dataStyle, amountStyle, percentStyle int) {
+	headerStyle, _ = f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Color: "FFFFFF", Size: 11},
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"4472C4"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+	})
+
+	dataStyle, _ = f.NewStyle(&excelize.Style{
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	})
+
+	amountStyle = newAmountStyle(f, 2)
+
+	percentStyle, _ = f.NewStyle(&excelize.Style{
+		NumFmt: 10,
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+		Alignment: &excelize.Alignment{Horizontal: "right", Vertical: "center"},
+	})
+	return headerStyle, dataStyle, amountStyle, percentStyle
+}
+
+// newAmountStyle verilen ondalık basamakla tutar stili oluşturur (0: #,##0, 2: #,##0.00, 3: #,##0.000)
+func newAmountStyle(f *excelize.File, decimals int) int {
+	style := &excelize.Style{
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+		Alignment: &excelize.Alignment{Horizontal: "right", Vertical: "center"},
+	}
+	switch decimals {
+	case 0:
+		style.NumFmt = 3
+	case 2:
+		style.NumFmt = 4
+	default:
+		numFmt := "#,##0." + strings.Repeat("0", decimals)
+		style.CustomNumFmt = &numFmt
+	}
+	id, _ := f.NewStyle(style)
+	return id
+}
+
 // writeOrdersToSheet belirtilen sheet'e siparişleri yazar
 func writeOrdersToSheet(f *excelize.File, sheetName string, orders []Order, headerStyle, dataStyle, amountStyle int) {
 	headers := []string{"Sipariş ID", "Tutar", "Para Birimi", "Bağış Kalemleri", "UTM Source", "UTM Medium", "UTM Campaign", "UTM Content", "UTM Term", "GAD Source", "GAD Campaign ID", "Traffic Channel", "Tarih", "Kayıt Tarihi"}
@@ -2539,6 +3923,9 @@ func writeOrdersToSheet(f *excelize.File, sheetName string, orders []Order, head
 		f.SetCellStyle(sheetName, cell, cell, headerStyle)
 	}
 
+	// Tutar hücresi siparişin para birimine göre biçimlenir; 2 basamaklılar amountStyle'ı kullanır
+	currencyStyles := map[int]int{2: amountStyle}
+
 	for i, o := range orders {
 		row := i + 2
 
@@ -2568,7 +3955,13 @@ func writeOrdersToSheet(f *excelize.File, sheetName string, orders []Order, head
 		for col := 1; col <= 14; col++ {
 			cell, _ := excelize.CoordinatesToCellName(col, row)
 			if col == 2 {
-				f.SetCellStyle(sheetName, cell, cell, amountStyle)
+				decimals := currencyDecimalPlaces(o.Currency)
+				style, ok := currencyStyles[decimals]
+				if !ok {
+					style = newAmountStyle(f, decimals)
+					currencyStyles[decimals] = style
+				}
+				f.SetCellStyle(sheetName, cell, cell, style)
 			} else {
 				f.SetCellStyle(sheetName, cell, cell, dataStyle)
 			}
@@ -2603,3 +3996,4205 @@ func sanitizeSheetName(name string) string {
 	}
 	return result
 }
+
+// sampleTrafficProfile örnek veri üretiminde kullanılan trafik profilini tutar
+type sampleTrafficProfile struct {
+	Weight         int
+	UTMSource      string
+	UTMMedium      string
+	Campaigns      []string
+	TrafficChannel string
+	GoogleAds      bool
+}
+
+// sampleItemProfile örnek veri üretiminde kullanılan bağış kalemini tutar
+type sampleItemProfile struct {
+	ItemID   string
+	ItemName string
+	Category string
+	MinPrice float64
+	MaxPrice float64
+}
+
+// Örnek veri trafik dağılımı (ağırlıklar yüzde olarak)
+var sampleTrafficProfiles = []sampleTrafficProfile{
+	{Weight: 35, UTMSource: "meta", UTMMedium: "paid_social", Campaigns: []string{"su_kuyusu_genel", "ramazan_iftar", "yetim_destek"}},
+	{Weight: 22, UTMSource: "google", UTMMedium: "cpc", Campaigns: []string{"su_kuyusu_arama", "kurban_arama", "genel_bagis_arama"}},
+	{Weight: 10, TrafficChannel: "google", GoogleAds: true},
+	{Weight: 8, UTMSource: "sms", UTMMedium: "sms", Campaigns: []string{"sms_ramazan", "sms_kurban"}},
+	{Weight: 7, UTMSource: "email", UTMMedium: "email", Campaigns: []string{"bulten_haftalik", "email_kurban"}},
+	{Weight: 5, UTMSource: "tiktok", UTMMedium: "paid_social", Campaigns: []string{"tiktok_su_kuyusu"}},
+	{Weight: 13},
+}
+
+// Örnek veri bağış kalemleri
+var sampleItemProfiles = []sampleItemProfile{
+	{ItemID: "su-kuyusu", ItemName: "Su Kuyusu", Category: "Su", MinPrice: 2500, MaxPrice: 30000},
+	{ItemID: "kurban-hissesi", ItemName: "Kurban Hissesi", Category: "Kurban", MinPrice: 3500, MaxPrice: 9000},
+	{ItemID: "iftar-paketi", ItemName: "İftar Paketi", Category: "Gıda", MinPrice: 150, MaxPrice: 1500},
+	{ItemID: "yetim-sponsorlugu", ItemName: "Yetim Sponsorluğu", Category: "Yetim", MinPrice: 500, MaxPrice: 3000},
+	{ItemID: "genel-bagis", ItemName: "Genel Bağış", Category: "Genel", MinPrice: 50, MaxPrice: 5000},
+}
+
+// pickSampleTrafficProfile ağırlıklara göre rastgele bir trafik profili seçer
+func pickSampleTrafficProfile(rng *rand.Rand) sampleTrafficProfile {
+	totalWeight := 0
+	for _, p := range sampleTrafficProfiles {
+		totalWeight += p.Weight
+	}
+	n := rng.Intn(totalWeight)
+	for _, p := range sampleTrafficProfiles {
+		if n < p.Weight {
+			return p
+		}
+		n -= p.Weight
+	}
+	return sampleTrafficProfiles[len(sampleTrafficProfiles)-1]
+}
+
+// generateSampleOrder verilen zaman aralığında rastgele bir test bağışı üretir
+func generateSampleOrder(rng *rand.Rand, startDate, endDate time.Time, seq int) Order {
+	profile := pickSampleTrafficProfile(rng)
+
+	// Çoğu bağış tek kalem, bazıları 2-3 kalem içerir
+	itemCount := 1
+	if rng.Intn(100) < 20 {
+		itemCount = 2 + rng.Intn(2)
+	}
+
+	var items []OrderItem
+	var amount float64
+	for i := 0; i < itemCount; i++ {
+		ip := sampleItemProfiles[rng.Intn(len(sampleItemProfiles))]
+		price := ip.MinPrice + rng.Float64()*(ip.MaxPrice-ip.MinPrice)
+		price = float64(int(price/10)) * 10
+		quantity := 1
+		if rng.Intn(100) < 15 {
+			quantity = 2 + rng.Intn(3)
+		}
+		items = append(items, OrderItem{ItemID: ip.ItemID, ItemName: ip.ItemName, Category: ip.Category, Quantity: quantity, Price: price})
+		amount += price * float64(quantity)
+	}
+
+	span := endDate.Sub(startDate)
+	eventTime := startDate
+	if span > 0 {
+		eventTime = startDate.Add(time.Duration(rng.Int63n(int64(span))))
+	}
+
+	order := Order{
+		OrderID:        fmt.Sprintf("test_%d_%d", time.Now().UnixNano(), seq),
+		Amount:         amount,
+		Currency:       "TRY",
+		Items:          items,
+		UTMSource:      profile.UTMSource,
+		UTMMedium:      profile.UTMMedium,
+		TrafficChannel: profile.TrafficChannel,
+		EventTime:      eventTime.UTC(),
+		IsTest:         true,
+	}
+	if len(profile.Campaigns) > 0 {
+		order.UTMCampaign = profile.Campaigns[rng.Intn(len(profile.Campaigns))]
+		order.UTMContent = fmt.Sprintf("kreatif_%d", 1+rng.Intn(4))
+	}
+	if profile.GoogleAds {
+		order.GadSource = "1"
+		order.GadCampaignID = fmt.Sprintf("2%010d", 1+rng.Intn(5))
+	}
+	return order
+}
+
+// handleOrnekVeriCommand /ornek_veri komutunu işler - Test bağışı üretir (sadece yönetici)
+func handleOrnekVeriCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	if !isAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Bu komut sadece yöneticiler tarafından kullanılabilir.")
+		bot.Send(msg)
+		return
+	}
+
+	ctx := context.Background()
+	args = strings.TrimSpace(args)
+	usage := "⚠️ Kullanım:\n/ornek_veri N — Son 30 gün için N test bağışı üret\n/ornek_veri N DD.MM.YYYY - DD.MM.YYYY — Tarih aralığında üret\n/ornek_veri sil — Tüm test bağışlarını sil"
+
+	if args == "sil" {
+		res, err := db.NewDelete().TableExpr("orders").Where("is_test = TRUE").Exec(ctx)
+		if err != nil {
+			log.Printf("Test verisi silme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		affected, _ := res.RowsAffected()
+		invalidateTodayAggregate()
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🧹 %d test bağışı silindi.", affected))
+		bot.Send(msg)
+		return
+	}
+
+	countStr, rangeStr, _ := strings.Cut(args, " ")
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 || count > 5000 {
+		msg := tgbotapi.NewMessage(chatID, usage+"\n\nN en fazla 5000 olabilir.")
+		bot.Send(msg)
+		return
+	}
+
+	turkeyLoc := getTurkeyLocation()
+	endDate := time.Now()
+	startDate := endDate.AddDate(0, 0, -30)
+	if strings.TrimSpace(rangeStr) != "" {
+		s, e, ok := parseDateRange(rangeStr)
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, usage)
+			bot.Send(msg)
+			return
+		}
+		// Tarihleri Türkiye saatine göre yorumla
+		startDate = time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, turkeyLoc)
+		endDate = time.Date(e.Year(), e.Month(), e.Day(), 23, 59, 59, 0, turkeyLoc)
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	orders := make([]Order, 0, count)
+	for i := 0; i < count; i++ {
+		orders = append(orders, generateSampleOrder(rng, startDate, endDate, i))
+	}
+
+	// 500'erli gruplar halinde kaydet
+	for i := 0; i < len(orders); i += 500 {
+		end := i + 500
+		if end > len(orders) {
+			end = len(orders)
+		}
+		batch := orders[i:end]
+		if err := saveOrders(ctx, batch); err != nil {
+			log.Printf("Test verisi kayıt hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Test verisi kaydedilemedi (%d/%d kaydedildi).", i, count))
+			bot.Send(msg)
+			return
+		}
+		for j := range batch {
+			addToTodayAggregate(&batch[j])
+		}
+	}
+
+	log.Printf("Test verisi üretildi: user=%d, adet=%d", userID, count)
+
+	var sb strings.Builder
+	sb.WriteString("🧪 <b>Test Verisi Üretildi</b>\n\n")
+	sb.WriteString(htmlf("🛒 <b>Bağış Sayısı:</b> %d\n", count))
+	sb.WriteString(htmlf("💰 <b>Toplam Tutar:</b> %s\n", orderTotals(orders).String()))
+	sb.WriteString(htmlf("📅 <b>Tarih Aralığı:</b> %s - %s\n\n", startDate.In(turkeyLoc).Format("02.01.2006"), endDate.In(turkeyLoc).Format("02.01.2006")))
+	sb.WriteString("<i>Test bağışlarını silmek için: /ornek_veri sil</i>")
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// campaignDimension kampanya etiket boyutunu tanımlar
+type campaignDimension struct {
+	Key   string
+	Title string
+}
+
+// Kampanya etiket boyutları (/rapor grupla=<key>)
+var campaignDimensions = []campaignDimension{
+	{Key: "ulke", Title: "Ülke"},
+	{Key: "urun", Title: "Ürün Grubu"},
+	{Key: "amac", Title: "Amaç"},
+	{Key: "sahip", Title: "Sorumlu"},
+}
+
+// findCampaignDimension anahtara göre etiket boyutunu bulur
+func findCampaignDimension(key string) (campaignDimension, bool) {
+	for _, d := range campaignDimensions {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return campaignDimension{}, false
+}
+
+// get etiketin verilen boyuttaki değerini döner
+func (t CampaignTag) get(key string) string {
+	switch key {
+	case "ulke":
+		return t.Country
+	case "urun":
+		return t.Product
+	case "amac":
+		return t.Objective
+	case "sahip":
+		return t.Owner
+	}
+	return ""
+}
+
+// set etiketin verilen boyuttaki değerini ayarlar
+func (t *CampaignTag) set(key, value string) bool {
+	switch key {
+	case "ulke":
+		t.Country = value
+	case "urun":
+		t.Product = value
+	case "amac":
+		t.Objective = value
+	case "sahip":
+		t.Owner = value
+	default:
+		return false
+	}
+	return true
+}
+
+var (
+	campaignTemplateOnce   sync.Once
+	campaignTemplateRegex  *regexp.Regexp
+	campaignTemplateFields []string
+)
+
+// getCampaignTemplate CAMPAIGN_NAME_TEMPLATE şablonunu regex'e çevirir
+// Örnek şablon: {ulke}_{urun}_{amac} → "af_su_kuyusu_bagis" = ulke:af, urun:su, amac:kuyusu_bagis
+func getCampaignTemplate() (*regexp.Regexp, []string) {
+	campaignTemplateOnce.Do(func() {
+		template := os.Getenv("CAMPAIGN_NAME_TEMPLATE")
+		if template == "" {
+			return
+		}
+
+		placeholder := regexp.MustCompile(`\{([a-z]+)\}`)
+		matches := placeholder.FindAllStringSubmatchIndex(template, -1)
+
+		var pattern strings.Builder
+		pattern.WriteString("^")
+		last := 0
+		for i, m := range matches {
+			pattern.WriteString(regexp.QuoteMeta(template[last:m[0]]))
+			// Son alan kalan tüm metni alır, diğerleri mümkün olan en kısa eşleşmeyi
+			if i == len(matches)-1 {
+				pattern.WriteString("(.+)")
+			} else {
+				pattern.WriteString("(.+?)")
+			}
+			campaignTemplateFields = append(campaignTemplateFields, template[m[2]:m[3]])
+			last = m[1]
+		}
+		pattern.WriteString(regexp.QuoteMeta(template[last:]))
+		pattern.WriteString("$")
+
+		re, err := regexp.Compile(pattern.String())
+		if err != nil {
+			log.Printf("UYARI: CAMPAIGN_NAME_TEMPLATE geçersiz: %v", err)
+			campaignTemplateFields = nil
+			return
+		}
+		campaignTemplateRegex = re
+		log.Printf("Kampanya isim şablonu: %s (alanlar: %v)", template, campaignTemplateFields)
+	})
+	return campaignTemplateRegex, campaignTemplateFields
+}
+
+// parseCampaignTags kampanya adını isim şablonuna göre etiketlere ayırır
+func parseCampaignTags(campaign string) CampaignTag {
+	tag := CampaignTag{Campaign: campaign}
+	re, fields := getCampaignTemplate()
+	if re == nil {
+		return tag
+	}
+	m := re.FindStringSubmatch(campaign)
+	if m == nil {
+		return tag
+	}
+	for i, field := range fields {
+		tag.set(field, m[i+1])
+	}
+	return tag
+}
+
+// resolveCampaignTags kampanyaların etiketlerini döner (açık etiketler şablondan çözülenlerin önüne geçer)
+func resolveCampaignTags(ctx context.Context, campaigns []string) (map[string]CampaignTag, error) {
+	result := make(map[string]CampaignTag, len(campaigns))
+	for _, c := range campaigns {
+		result[c] = parseCampaignTags(c)
+	}
+	if len(campaigns) == 0 {
+		return result, nil
+	}
+
+	var explicit []CampaignTag
+	if err := db.NewSelect().Model(&explicit).Where("campaign IN (?)", bun.In(campaigns)).Scan(ctx); err != nil {
+		return result, err
+	}
+	for _, e := range explicit {
+		tag := result[e.Campaign]
+		for _, d := range campaignDimensions {
+			if v := e.get(d.Key); v != "" {
+				tag.set(d.Key, v)
+			}
+		}
+		result[e.Campaign] = tag
+	}
+	return result, nil
+}
+
+// parseGroupArg argümanlardan grupla=<boyut> ifadesini ayıklar, kalan argümanları döner
+func parseGroupArg(args string) (dimension string, rest string) {
+	var restParts []string
+	for _, field := range strings.Fields(args) {
+		if v, ok := strings.CutPrefix(field, "grupla="); ok {
+			dimension = strings.ToLower(v)
+			continue
+		}
+		restParts = append(restParts, field)
+	}
+	return dimension, strings.Join(restParts, " ")
+}
+
+// campaignDimensionKeys etiket boyutu anahtarlarını virgülle ayrılmış döner
+func campaignDimensionKeys() string {
+	var keys []string
+	for _, d := range campaignDimensions {
+		keys = append(keys, d.Key)
+	}
+	return strings.Join(keys, ", ")
+}
+
+// reportArgs rapor komutlarının ortak argümanları (tarih aralığı ve grupla=<boyut>)
+type reportArgs struct {
+	StartDate     time.Time
+	EndDate       time.Time
+	HasDateFilter bool
+	Group         *campaignDimension // grupla= verilmemişse nil
+}
+
+// parseReportArgs "grupla=ulke 01.03.2025 - 31.03.2025" gibi argümanları ayrıştırır
+func parseReportArgs(args string) (reportArgs, error) {
+	dimensionKey, rest := parseGroupArg(args)
+
+	var r reportArgs
+	r.StartDate, r.EndDate, r.HasDateFilter = parseDateRange(rest)
+
+	if dimensionKey != "" {
+		dimension, ok := findCampaignDimension(dimensionKey)
+		if !ok {
+			return r, fmt.Errorf("geçersiz grupla boyutu: %s (boyutlar: %s)", dimensionKey, campaignDimensionKeys())
+		}
+		r.Group = &dimension
+	}
+	return r, nil
+}
+
+// parseReportQuery CSV endpoint'lerinin ?tarih= ve ?grupla= parametrelerini ayrıştırır
+func parseReportQuery(c *fiber.Ctx) (reportArgs, error) {
+	args := c.Query("tarih")
+	if group := c.Query("grupla"); group != "" {
+		args += " grupla=" + group
+	}
+	return parseReportArgs(args)
+}
+
+// filter tarih aralığı verilmişse orders sorgusuna uygular
+func (r reportArgs) filter(query *bun.SelectQuery) *bun.SelectQuery {
+	if r.HasDateFilter {
+		query = query.Where("event_time >= ?", r.StartDate).Where("event_time <= ?", r.EndDate)
+	}
+	return query
+}
+
+// dateLine tarih filtresi verilmişse rapor başlığının altına eklenecek satırı döner
+func (r reportArgs) dateLine() string {
+	if !r.HasDateFilter {
+		return ""
+	}
+	return htmlf("📅 <b>Tarih:</b> %s - %s\n\n", r.StartDate.Format("02.01.2006"), r.EndDate.Format("02.01.2006"))
+}
+
+// Etiket gruplarının alt kırılım ifadeleri (queryTagGroups)
+const (
+	tagBreakdownSource   = "COALESCE(src.name, 'Bilinmiyor')"
+	tagBreakdownMedium   = "COALESCE(med.name, 'Bilinmiyor')"
+	tagBreakdownCampaign = "COALESCE(cmp.name, 'Belirtilmemiş')"
+	tagBreakdownDay      = "to_char((o.event_time AT TIME ZONE 'Europe/Istanbul')::date, 'YYYY-MM-DD')"
+)
+
+// tagGroupRow etiket grubundaki bir alt kırılım satırı (kaynak, ortam, kampanya veya gün)
+type tagGroupRow struct {
+	Name  string
+	Total float64
+	Count int
+}
+
+// tagGroup etiket değerine ve para birimine göre toplanmış bağışlar
+type tagGroup struct {
+	Name     string
+	Currency string
+	Total    float64
+	Count    int
+	Rows     []tagGroupRow // Toplama göre azalan
+}
+
+// queryTagGroups bağışları kampanya etiket boyutuna göre gruplar; breakdown her grubun alt kırılımıdır
+// Farklı para birimleri toplanmaz: her etiket değeri para birimi başına ayrı grup olur, gruplar
+// para birimine göre (en çok bağış alan önce), para birimi içinde toplama göre sıralanır
+func queryTagGroups(ctx context.Context, scope DataScope, r reportArgs, breakdown string) ([]tagGroup, moneyTotals, error) {
+	var rows []struct {
+		Campaign string  `bun:"campaign"`
+		Name     string  `bun:"name"`
+		Currency string  `bun:"currency"`
+		Total    float64 `bun:"total"`
+		Count    int     `bun:"count"`
+	}
+
+	err := db.NewSelect().
+		TableExpr("(?) AS o", r.filter(scope.orders())).
+		Join("LEFT JOIN utm_sources AS src ON src.id = o.utm_source_id").
+		Join("LEFT JOIN utm_mediums AS med ON med.id = o.utm_medium_id").
+		Join("LEFT JOIN utm_campaigns AS cmp ON cmp.id = o.utm_campaign_id").
+		ColumnExpr("COALESCE(cmp.name, '') AS campaign").
+		ColumnExpr(breakdown+" AS name").
+		ColumnExpr("o.currency").
+		ColumnExpr("SUM(o.amount) AS total").
+		ColumnExpr("COUNT(*) AS count").
+		GroupExpr("1, 2, 3").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if !seen[row.Campaign] {
+			seen[row.Campaign] = true
+			names = append(names, row.Campaign)
+		}
+	}
+	tags, err := resolveCampaignTags(ctx, names)
+	if err != nil {
+		log.Printf("Kampanya etiketleri okunamadı: %v", err)
+	}
+
+	groupMap := make(map[bucketKey]*tagGroup)
+	rowIndex := make(map[bucketKey]map[string]int)
+	var grandTotals moneyTotals
+	for _, row := range rows {
+		name := tags[row.Campaign].get(r.Group.Key)
+		if name == "" {
+			name = "Belirtilmemiş"
+		}
+		key := bucketKey{Name: name, Currency: row.Currency}
+		g, exists := groupMap[key]
+		if !exists {
+			g = &tagGroup{Name: name, Currency: row.Currency}
+			groupMap[key] = g
+			rowIndex[key] = make(map[string]int)
+		}
+		g.Total += row.Total
+		g.Count += row.Count
+		grandTotals.add(row.Currency, row.Total, row.Count)
+
+		if i, ok := rowIndex[key][row.Name]; ok {
+			g.Rows[i].Total += row.Total
+			g.Rows[i].Count += row.Count
+		} else {
+			rowIndex[key][row.Name] = len(g.Rows)
+			g.Rows = append(g.Rows, tagGroupRow{Name: row.Name, Total: row.Total, Count: row.Count})
+		}
+	}
+
+	grandTotals.sort()
+	groups := make([]tagGroup, 0, len(groupMap))
+	for _, g := range groupMap {
+		sort.Slice(g.Rows, func(i, j int) bool { return g.Rows[i].Total > g.Rows[j].Total })
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Currency != groups[j].Currency {
+			return grandTotals.rank(groups[i].Currency) < grandTotals.rank(groups[j].Currency)
+		}
+		return groups[i].Total > groups[j].Total
+	})
+	return groups, grandTotals, nil
+}
+
+// sendTagGroupReport grupla=<boyut> verilen rapor komutlarının ortak çıktısı
+// average true ise tutarlar yerine ortalama bağış gösterilir (/ortalama)
+func sendTagGroupReport(bot *tgbotapi.BotAPI, chatID int64, userID int64, r reportArgs, title string, breakdown string, average bool) {
+	ctx := context.Background()
+
+	groups, grandTotals, err := queryTagGroups(ctx, getDataScope(ctx, chatID, userID), r, breakdown)
+	if err != nil {
+		log.Printf("Etiket grubu sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(htmlf("🗂️ <b>%s — %s Bazında</b>\n\n", title, r.Group.Title))
+	sb.WriteString(r.dateLine())
+
+	if len(groups) == 0 {
+		sb.WriteString("ℹ️ Bu dönemde veri bulunmamaktadır.")
+	} else {
+		const rowsPerGroup = 5
+		sections := currencySections{multiple: len(grandTotals) > 1}
+		for _, g := range groups {
+			emoji := getEmojiByRank(sections.next(&sb, g.Currency))
+			sb.WriteString(htmlf("%s <b>%s</b>\n", emoji, g.Name))
+			if average {
+				sb.WriteString(htmlf("   📊 Ort: %s (%d bağış)\n", formatMoney(g.Total/float64(g.Count), g.Currency), g.Count))
+			} else {
+				sb.WriteString(htmlf("   💰 %s (%d bağış) - %%%.1f\n", formatMoney(g.Total, g.Currency), g.Count, grandTotals.share(g.Currency, g.Total)))
+			}
+			for _, row := range g.Rows[:min(len(g.Rows), rowsPerGroup)] {
+				if average {
+					sb.WriteString(htmlf("      • %s: Ort %s (%d)\n", row.Name, formatMoney(row.Total/float64(row.Count), g.Currency), row.Count))
+				} else {
+					sb.WriteString(htmlf("      • %s: %s (%d)\n", row.Name, formatMoney(row.Total, g.Currency), row.Count))
+				}
+			}
+			if len(g.Rows) > rowsPerGroup {
+				sb.WriteString(htmlf("      … ve %d daha\n", len(g.Rows)-rowsPerGroup))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString(htmlf("📈 <b>Toplam:</b> %s", grandTotals.String()))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// sendReportArgsError geçersiz rapor argümanları için uyarı gönderir
+func sendReportArgsError(bot *tgbotapi.BotAPI, chatID int64, err error) {
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ %v", err))
+	bot.Send(msg)
+}
+
+// handleRaporCommand /rapor komutunu işler - Kampanya etiketlerine göre gruplanmış rapor
+func handleRaporCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	r, err := parseReportArgs(args)
+	if err != nil || r.Group == nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Kullanım:\n/rapor grupla=<boyut> [DD.MM.YYYY - DD.MM.YYYY]\n\nBoyutlar: %s\n\nÖrnek: /rapor grupla=ulke", campaignDimensionKeys()))
+		bot.Send(msg)
+		return
+	}
+
+	stopProgress := startProgress(bot, chatID, tgbotapi.ChatTyping, "Rapor")
+	defer stopProgress()
+
+	sendTagGroupReport(bot, chatID, userID, r, "Kampanya Raporu", tagBreakdownCampaign, false)
+}
+
+// handleEtiketCommand /etiket komutunu işler - Kampanya etiketlerini gösterir/ayarlar
+func handleEtiketCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+	fields := strings.Fields(args)
+
+	if len(fields) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Kullanım:\n/etiket <kampanya> — Etiketleri göster\n/etiket <kampanya> ulke=af urun=su_kuyusu amac=bagis sahip=ahmet — Etiketle\n/etiket <kampanya> sil — Açık etiketleri sil")
+		bot.Send(msg)
+		return
+	}
+
+	campaign := fields[0]
+
+	// Sadece görüntüleme
+	if len(fields) == 1 {
+		tags, err := resolveCampaignTags(ctx, []string{campaign})
+		if err != nil {
+			log.Printf("Kampanya etiketleri okunamadı: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		tag := tags[campaign]
+
+		var sb strings.Builder
+		sb.WriteString(htmlf("🏷️ <b>%s</b>\n\n", campaign))
+		for _, d := range campaignDimensions {
+			value := tag.get(d.Key)
+			if value == "" {
+				value = "-"
+			}
+			sb.WriteString(htmlf("  • %s: %s\n", d.Title, value))
+		}
+
+		msg := tgbotapi.NewMessage(chatID, sb.String())
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	if !isAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Etiketleri sadece yöneticiler değiştirebilir.")
+		bot.Send(msg)
+		return
+	}
+
+	if fields[1] == "sil" {
+		if _, err := db.NewDelete().Model((*CampaignTag)(nil)).Where("campaign = ?", campaign).Exec(ctx); err != nil {
+			log.Printf("Kampanya etiketi silme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑️ %s kampanyasının açık etiketleri silindi.", campaign))
+		bot.Send(msg)
+		return
+	}
+
+	// Mevcut açık etiketleri al, yenilerini üzerine yaz
+	tag := CampaignTag{Campaign: campaign}
+	if err := db.NewSelect().Model(&tag).WherePK().Scan(ctx); err != nil && err != sql.ErrNoRows {
+		log.Printf("Kampanya etiketi okuma hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	for _, f := range fields[1:] {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || !tag.set(strings.ToLower(key), sanitizeUTMValue(value)) {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Geçersiz etiket: %s\n\nGeçerli boyutlar: ulke, urun, amac, sahip", f))
+			bot.Send(msg)
+			return
+		}
+	}
+	tag.UpdatedAt = time.Now()
+
+	_, err := db.NewInsert().
+		Model(&tag).
+		On("CONFLICT (campaign) DO UPDATE").
+		Set("country = EXCLUDED.country").
+		Set("product = EXCLUDED.product").
+		Set("objective = EXCLUDED.objective").
+		Set("owner = EXCLUDED.owner").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Kampanya etiketi kayıt hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ %s kampanyası etiketlendi.", campaign))
+	bot.Send(msg)
+}
+
+// UTM sütunlarının boyut (lookup) tabloları
+var utmDimensionTables = map[string]string{
+	"utm_source":   "utm_sources",
+	"utm_medium":   "utm_mediums",
+	"utm_campaign": "utm_campaigns",
+}
+
+// dimensionIDCache boyut değerlerinin ID'lerini önbellekte tutar (tablo -> değer -> id)
+var dimensionIDCache = make(map[string]map[string]int64)
+var dimensionIDCacheMutex sync.RWMutex
+
+// resolveDimensionID boyut tablosunda değerin ID'sini bulur, yoksa ekler (boş değer için 0 döner)
+func resolveDimensionID(ctx context.Context, table, name string) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	dimensionIDCacheMutex.RLock()
+	id, ok := dimensionIDCache[table][name]
+	dimensionIDCacheMutex.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (name) VALUES (?) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id", table)
+	if err := db.QueryRowContext(ctx, query, name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s boyut değeri çözülemedi: %w", table, err)
+	}
+
+	dimensionIDCacheMutex.Lock()
+	if dimensionIDCache[table] == nil {
+		dimensionIDCache[table] = make(map[string]int64)
+	}
+	dimensionIDCache[table][name] = id
+	dimensionIDCacheMutex.Unlock()
+	return id, nil
+}
+
+// resolveOrderDimensions siparişin UTM değerlerini boyut ID'lerine çevirir
+func resolveOrderDimensions(ctx context.Context, order *Order) error {
+	var err error
+	if order.UTMSourceID, err = resolveDimensionID(ctx, "utm_sources", order.UTMSource); err != nil {
+		return err
+	}
+	if order.UTMMediumID, err = resolveDimensionID(ctx, "utm_mediums", order.UTMMedium); err != nil {
+		return err
+	}
+	if order.UTMCampaignID, err = resolveDimensionID(ctx, "utm_campaigns", order.UTMCampaign); err != nil {
+		return err
+	}
+	return nil
+}
+
+// saveOrder siparişi boyut ID'leri ve kalem satırlarıyla birlikte kaydeder
+func saveOrder(ctx context.Context, order *Order) error {
+	orders := []Order{*order}
+	err := saveOrders(ctx, orders)
+	*order = orders[0]
+	return err
+}
+
+// saveOrders siparişleri toplu olarak boyut ID'leri ve kalem satırlarıyla birlikte kaydeder
+func saveOrders(ctx context.Context, orders []Order) error {
+	for i := range orders {
+		if err := resolveOrderDimensions(ctx, &orders[i]); err != nil {
+			return err
+		}
+	}
+
+	err := insertOrders(ctx, orders)
+	// Ham sütunlar bu örnek çalışırken kaldırıldıysa (-drop-raw-utm-columns) onlarsız yeniden denenir
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) && pgErr.Field('C') == "42703" && rawUTMColumns.CompareAndSwap(true, false) {
+		log.Println("Ham UTM sütunları kaldırılmış, kayıtlar sadece boyut ID'leriyle yazılacak")
+		err = insertOrders(ctx, orders)
+	}
+	return err
+}
+
+// insertOrders siparişleri ve kalem satırlarını tek transaction'da yazar
+func insertOrders(ctx context.Context, orders []Order) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		query := tx.NewInsert().Model(&orders)
+		if !rawUTMColumns.Load() {
+			query = query.ExcludeColumn("utm_source", "utm_medium", "utm_campaign")
+		}
+		if _, err := query.Exec(ctx); err != nil {
+			return err
+		}
+
+		var rows []OrderItemRow
+		for _, o := range orders {
+			for _, item := range o.Items {
+				rows = append(rows, OrderItemRow{
+					OrderPK:  o.ID,
+					ItemID:   item.ItemID,
+					ItemName: item.ItemName,
+					Category: item.Category,
+					Quantity: item.Quantity,
+					Price:    item.Price,
+				})
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		_, err := tx.NewInsert().Model(&rows).Exec(ctx)
+		return err
+	})
+}
+
+// dimensionIDFilter UTM sütununu boyut tablosundaki ada göre ID üzerinden filtreleyen koşulu döner (ad ? ile verilir)
+func dimensionIDFilter(column string) string {
+	return fmt.Sprintf("%s_id = (SELECT id FROM %s WHERE name = ?)", column, utmDimensionTables[column])
+}
+
+// dimensionTotalsQuery toplam sorgusunu UTM boyut ID'sine göre gruplar ve boyut adını ekler
+// inner: filtreleri ve toplam sütunlarını içeren orders sorgusu, columns: dışarı aktarılacak toplam sütunları
+func dimensionTotalsQuery(inner *bun.SelectQuery, column string, columns ...string) *bun.SelectQuery {
+	inner = inner.
+		ColumnExpr(column + "_id AS dim_id").
+		GroupExpr(column + "_id")
+
+	query := db.NewSelect().
+		TableExpr("(?) AS t", inner).
+		Join(fmt.Sprintf("LEFT JOIN %s AS d ON d.id = t.dim_id", utmDimensionTables[column])).
+		ColumnExpr(fmt.Sprintf("COALESCE(d.name, 'Bilinmiyor') AS %s", column))
+
+	for _, c := range columns {
+		query = query.ColumnExpr("t." + c)
+	}
+	return query
+}
+
+// requireReportsToken rapor endpoint'leri için REPORTS_API_TOKEN doğrulaması yapar
+// Token ?token= parametresi (IMPORTDATA header gönderemez) veya Authorization: Bearer ile verilebilir
+// Token geneldir: /kapsam tanımları bu endpoint'lere uygulanmaz, raporlar tüm verileri içerir
+func requireReportsToken(c *fiber.Ctx) error {
+	expected := os.Getenv("REPORTS_API_TOKEN")
+	if expected == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Rapor API'si yapılandırılmamış",
+		})
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Yetkisiz erişim",
+		})
+	}
+	return c.Next()
+}
+
+// requireGoogleAdsSyncToken kampanya senkronizasyonu için GOOGLE_ADS_SYNC_TOKEN doğrulaması yapar
+// Senkronizasyon listede olmayan kampanyaları sildiğinden salt okunur rapor token'ı kabul edilmez;
+// token URL'de loglanmasın diye sadece Authorization: Bearer ile verilebilir
+func requireGoogleAdsSyncToken(c *fiber.Ctx) error {
+	expected := os.Getenv("GOOGLE_ADS_SYNC_TOKEN")
+	if expected == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Google Ads senkronizasyonu yapılandırılmamış",
+		})
+	}
+
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Yetkisiz erişim",
+		})
+	}
+	return c.Next()
+}
+
+// sendCSV satırları CSV olarak döner
+func sendCSV(c *fiber.Ctx, filename string, rows [][]string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		log.Printf("CSV yazma hatası: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "CSV oluşturulamadı",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", filename))
+	return c.Send(buf.Bytes())
+}
+
+// csvAmount tutarı CSV için para biriminin ondalık basamağıyla biçimlendirir (para birimi ayrı sütundadır)
+func csvAmount(amount float64, currency string) string {
+	return formatAmount(amount, currency)
+}
+
+// handleSourcesCSV GET /reports/sources.csv - /kaynaklar ile aynı veri
+func handleSourcesCSV(c *fiber.Ctx) error {
+	r, err := parseReportQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if r.Group != nil {
+		return sendTagGroupCSV(c, "sources.csv", r, "kaynak", tagBreakdownSource)
+	}
+
+	sources, err := querySourceTotals(c.Context(), DataScope{}, r.StartDate, r.EndDate, r.HasDateFilter)
+	if err != nil {
+		log.Printf("Kaynaklar CSV sorgu hatası: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Veritabanı hatası",
+		})
+	}
+
+	rows := [][]string{{"kaynak", "para_birimi", "bagis_sayisi", "toplam_tutar"}}
+	for _, s := range sources {
+		rows = append(rows, []string{s.UTMSource, s.Currency, strconv.Itoa(s.Count), csvAmount(s.Total, s.Currency)})
+	}
+	return sendCSV(c, "sources.csv", rows)
+}
+
+// handleCampaignsCSV GET /reports/campaigns.csv - /kampanyalar ile aynı veri (limitsiz)
+func handleCampaignsCSV(c *fiber.Ctx) error {
+	r, err := parseReportQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if r.Group != nil {
+		return sendTagGroupCSV(c, "campaigns.csv", r, "kampanya", tagBreakdownCampaign)
+	}
+
+	campaigns, err := queryCampaignTotals(c.Context(), DataScope{}, r.StartDate, r.EndDate, r.HasDateFilter, 0)
+	if err != nil {
+		log.Printf("Kampanyalar CSV sorgu hatası: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Veritabanı hatası",
+		})
+	}
+
+	rows := [][]string{{"kampanya", "para_birimi", "bagis_sayisi", "toplam_tutar", "ortalama_tutar"}}
+	for _, cp := range campaigns {
+		rows = append(rows, []string{cp.UTMCampaign, cp.Currency, strconv.Itoa(cp.Count), csvAmount(cp.Total, cp.Currency), csvAmount(cp.AvgAmount, cp.Currency)})
+	}
+	return sendCSV(c, "campaigns.csv", rows)
+}
+
+// handleDailyCSV GET /reports/daily.csv - Türkiye saatine göre gün gün toplamlar (varsayılan son 30 gün)
+func handleDailyCSV(c *fiber.Ctx) error {
+	r, err := parseReportQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !r.HasDateFilter {
+		r.StartDate, _, _ = getDayRangeUTC(-29)
+		_, r.EndDate, _ = getDayRangeUTC(0)
+		r.HasDateFilter = true
+	}
+	if r.Group != nil {
+		return sendTagGroupCSV(c, "daily.csv", r, "tarih", tagBreakdownDay)
+	}
+
+	var days []struct {
+		Day      time.Time `bun:"day"`
+		Currency string    `bun:"currency"`
+		Total    float64   `bun:"total"`
+		Count    int       `bun:"count"`
+	}
+
+	err = db.NewSelect().
+		TableExpr("orders").
+		ColumnExpr("(event_time AT TIME ZONE 'Europe/Istanbul')::date as day").
+		ColumnExpr("currency").
+		ColumnExpr("SUM(amount) as total").
+		ColumnExpr("COUNT(*) as count").
+		Where("event_time >= ?", r.StartDate).
+		Where("event_time <= ?", r.EndDate).
+		GroupExpr("1, 2").
+		OrderExpr("1, 2").
+		Scan(c.Context(), &days)
+	if err != nil {
+		log.Printf("Günlük CSV sorgu hatası: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Veritabanı hatası",
+		})
+	}
+
+	rows := [][]string{{"tarih", "para_birimi", "bagis_sayisi", "toplam_tutar"}}
+	for _, d := range days {
+		rows = append(rows, []string{d.Day.Format("02.01.2006"), d.Currency, strconv.Itoa(d.Count), csvAmount(d.Total, d.Currency)})
+	}
+	return sendCSV(c, "daily.csv", rows)
+}
+
+// sendTagGroupCSV ?grupla=<boyut> verilen CSV raporlarını etiket grubu sütunuyla döner
+// Gün kırılımında satırlar tarihe göre, diğerlerinde toplama göre sıralanır
+func sendTagGroupCSV(c *fiber.Ctx, filename string, r reportArgs, column string, breakdown string) error {
+	groups, _, err := queryTagGroups(c.Context(), DataScope{}, r, breakdown)
+	if err != nil {
+		log.Printf("Etiket grubu CSV sorgu hatası: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Veritabanı hatası",
+		})
+	}
+
+	rows := [][]string{{"grup", column, "para_birimi", "bagis_sayisi", "toplam_tutar"}}
+	for _, g := range groups {
+		if breakdown == tagBreakdownDay {
+			sort.Slice(g.Rows, func(i, j int) bool { return g.Rows[i].Name < g.Rows[j].Name })
+		}
+		for _, row := range g.Rows {
+			name := row.Name
+			if breakdown == tagBreakdownDay {
+				if day, err := time.Parse("2006-01-02", name); err == nil {
+					name = day.Format("02.01.2006")
+				}
+			}
+			rows = append(rows, []string{g.Name, name, g.Currency, strconv.Itoa(row.Count), csvAmount(row.Total, g.Currency)})
+		}
+	}
+	return sendCSV(c, filename, rows)
+}
+
+// UsageCounter kullanıcıların günlük işlem sayaçlarını tutar (kota için)
+type UsageCounter struct {
+	bun.BaseModel `bun:"table:usage_counters,alias:uc"`
+
+	UserID int64     `bun:"user_id,pk"`
+	Action string    `bun:"action,pk"`
+	Day    time.Time `bun:"day,pk,type:date"`
+	Count  int       `bun:"count,notnull"`
+}
+
+// quotaRule bir işlem için günlük kota kuralını tanımlar
+type quotaRule struct {
+	Role         string // Kotanın bağlı olduğu rol (quotaRoleEnvVars)
+	Title        string // Kullanıcıya gösterilen işlem adı
+	EnvVar       string // Limiti değiştiren environment variable
+	DefaultLimit int
+}
+
+// Rol bazlı günlük kotalar (yöneticiler muaf, limit 0 = sınırsız)
+// İşlemin rolünde olmayan kullanıcılar QUOTA_DEFAULT_PER_DAY limitini alır
+var quotaRules = map[string]quotaRule{
+	"build":  {Role: "builder", Title: "UTM link oluşturma", EnvVar: "QUOTA_BUILD_PER_DAY", DefaultLimit: 30},
+	"export": {Role: "analyst", Title: "Excel dışa aktarma", EnvVar: "QUOTA_EXPORT_PER_DAY", DefaultLimit: 10},
+}
+
+// Rolü olmayan kullanıcıların her işlem için varsayılan günlük limiti
+const defaultQuotaLimit = 3
+
+// Kota rollerinin üyelerini tanımlayan environment variable'lar
+// Değişken boşsa rol tüm kullanıcıları kapsar (yöneticiler hariç)
+var quotaRoleEnvVars = map[string]string{
+	"builder": "QUOTA_BUILDER_USER_IDS",
+	"analyst": "QUOTA_ANALYST_USER_IDS",
+}
+
+// hasQuotaRole kullanıcının verilen kota rolünde olup olmadığını döner
+func hasQuotaRole(userID int64, role string) bool {
+	members := parseUserIDs(os.Getenv(quotaRoleEnvVars[role]))
+	if len(members) == 0 {
+		return true
+	}
+	for _, id := range members {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaApplies işlemin kotasının kullanıcıya uygulanıp uygulanmadığını döner
+func quotaApplies(userID int64, action string) bool {
+	return !isAdmin(userID) && getQuotaLimit(userID, action) > 0
+}
+
+// getQuotaLimit kullanıcının işlem için günlük limitini döner
+// İşlemin rolündeki kullanıcılar rol limitini, diğerleri varsayılan limiti alır
+func getQuotaLimit(userID int64, action string) int {
+	rule := quotaRules[action]
+	if !hasQuotaRole(userID, rule.Role) {
+		return getEnvLimit("QUOTA_DEFAULT_PER_DAY", defaultQuotaLimit)
+	}
+	return getEnvLimit(rule.EnvVar, rule.DefaultLimit)
+}
+
+// getEnvLimit environment variable'daki limiti okur (geçersiz veya boşsa varsayılan)
+func getEnvLimit(envVar string, defaultLimit int) int {
+	if limit, err := strconv.Atoi(os.Getenv(envVar)); err == nil && limit >= 0 {
+		return limit
+	}
+	return defaultLimit
+}
+
+// quotaDay Türkiye saatine göre bugünün tarihini döner (sayaçlar gece yarısı sıfırlanır)
+func quotaDay() time.Time {
+	now := getTurkeyNow()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// getQuotaUsage kullanıcının bugünkü işlem sayısını döner
+func getQuotaUsage(ctx context.Context, userID int64, action string) (int, error) {
+	var count int
+	err := db.NewSelect().
+		Model((*UsageCounter)(nil)).
+		Column("count").
+		Where("user_id = ?", userID).
+		Where("action = ?", action).
+		Where("day = ?", quotaDay()).
+		Scan(ctx, &count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
+
+// enforceQuota kullanıcının kotasını kontrol eder, aşılmışsa bilgi mesajı gönderir ve false döner
+// Kota yumuşaktır: veritabanı hatasında işleme izin verilir
+func enforceQuota(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, userID int64, action string) bool {
+	if !quotaApplies(userID, action) {
+		return true
+	}
+
+	limit := getQuotaLimit(userID, action)
+	used, err := getQuotaUsage(ctx, userID, action)
+	if err != nil {
+		log.Printf("Kota sorgu hatası (user=%d, action=%s): %v", userID, action, err)
+		return true
+	}
+	if used < limit {
+		return true
+	}
+
+	log.Printf("Kota aşıldı: user=%d, rol=%s, action=%s, kullanım=%d/%d", userID, quotaRules[action].Role, action, used, limit)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ Bugünkü %s limitinize ulaştınız (%d/%d).\n\nLimitler her gece 00:00'da sıfırlanır. Daha fazlasına ihtiyacınız varsa bir yöneticiye başvurun.", quotaRules[action].Title, used, limit))
+	bot.Send(msg)
+	return false
+}
+
+// consumeQuota kullanıcının bugünkü işlem sayacını bir artırır (kota uygulanmıyorsa sayılmaz)
+func consumeQuota(ctx context.Context, userID int64, action string) {
+	if !quotaApplies(userID, action) {
+		return
+	}
+
+	counter := &UsageCounter{UserID: userID, Action: action, Day: quotaDay(), Count: 1}
+	_, err := db.NewInsert().
+		Model(counter).
+		On("CONFLICT (user_id, action, day) DO UPDATE").
+		Set("count = uc.count + 1").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Kota sayacı güncellenemedi (user=%d, action=%s): %v", userID, action, err)
+	}
+}
+
+// handleKotaCommand /kota komutunu işler - Kullanıcının günlük kullanımını gösterir
+func handleKotaCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	ctx := context.Background()
+
+	var sb strings.Builder
+	sb.WriteString("📏 <b>Günlük Kullanım Limitleri</b>\n\n")
+
+	if isAdmin(userID) {
+		sb.WriteString("🔐 Yönetici olduğunuz için limitler size uygulanmaz.")
+	} else {
+		for _, action := range []string{"build", "export"} {
+			limit := getQuotaLimit(userID, action)
+			used, _ := getQuotaUsage(ctx, userID, action)
+			if !quotaApplies(userID, action) {
+				sb.WriteString(htmlf("• %s: %d (sınırsız)\n", quotaRules[action].Title, used))
+			} else {
+				sb.WriteString(htmlf("• %s: %d / %d\n", quotaRules[action].Title, used, limit))
+			}
+		}
+		sb.WriteString("\n<i>Limitler her gece 00:00'da sıfırlanır.</i>")
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// UTMPreset ajans/partnerlerle deep link ile paylaşılan hazır kampanya ayarını tutar
+type UTMPreset struct {
+	bun.BaseModel `bun:"table:utm_presets,alias:up"`
+
+	Name        string    `bun:"name,pk"`
+	UTMSource   string    `bun:"utm_source,notnull"`
+	UTMMedium   string    `bun:"utm_medium,notnull"`
+	UTMCampaign string    `bun:"utm_campaign,notnull"`
+	CreatedBy   int64     `bun:"created_by"`
+	CreatedAt   time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Hazır ayar adları deep link start parametresine uygun olmalı (en fazla 64 karakter, "preset_" öneki dahil)
+var presetNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// presetDeepLink hazır ayarın paylaşılabilir Telegram linkini döner
+func presetDeepLink(bot *tgbotapi.BotAPI, name string) string {
+	return fmt.Sprintf("https://t.me/%s?start=preset_%s", bot.Self.UserName, name)
+}
+
+// startPresetBuildProcess hazır ayarla UTM oluşturma sürecini başlatır (sadece URL ve içerik sorulur)
+func startPresetBuildProcess(bot *tgbotapi.BotAPI, chatID int64, userID int64, presetName string) {
+	ctx := context.Background()
+
+	if !enforceQuota(ctx, bot, chatID, userID, "build") {
+		return
+	}
+
+	preset := UTMPreset{Name: presetName}
+	if err := db.NewSelect().Model(&preset).WherePK().Scan(ctx); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Hazır ayar sorgu hatası: %v", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Bu hazır ayar bulunamadı veya kaldırılmış. Linki paylaşan kişiyle iletişime geçin ya da /build ile manuel oluşturun.")
+		bot.Send(msg)
+		return
+	}
+
+	sessionsMutex.Lock()
+	sessions[userID] = &UserSession{
+		Step:      1,
+		Preset:    preset.Name,
+		UTMSource: preset.UTMSource,
+		UTMMedium: preset.UTMMedium,
+		Campaign:  preset.UTMCampaign,
+	}
+	sessionsMutex.Unlock()
+	log.Printf("Hazır ayarla session oluşturuldu: userID=%d, preset=%s", userID, preset.Name)
+
+	var sb strings.Builder
+	sb.WriteString(htmlf("🔗 <b>%s</b> hazır ayarı yüklendi\n\n", preset.Name))
+	sb.WriteString(htmlf("• utm_source: <code>%s</code>\n", preset.UTMSource))
+	sb.WriteString(htmlf("• utm_medium: <code>%s</code>\n", preset.UTMMedium))
+	sb.WriteString(htmlf("• utm_campaign: <code>%s</code>\n\n", preset.UTMCampaign))
+	sb.WriteString("📝 <b>Adım 1/2: Kaynak URL</b>\n\nLütfen UTM parametreleri eklemek istediğiniz URL'yi girin.\n\nÖrnek: <code>https://hayratyardim.org/bagis/genel-su-kuyusu/</code>")
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// handlePresetCommand /preset komutunu işler - Hazır ayarları listeler/ekler/siler
+func handlePresetCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+	fields := strings.Fields(args)
+
+	// Listeleme
+	if len(fields) == 0 {
+		var presets []UTMPreset
+		if err := db.NewSelect().Model(&presets).OrderExpr("name").Scan(ctx); err != nil {
+			log.Printf("Hazır ayar listeleme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+
+		var sb strings.Builder
+		sb.WriteString("🔗 <b>Hazır Kampanya Ayarları</b>\n\n")
+		if len(presets) == 0 {
+			sb.WriteString("ℹ️ Henüz hazır ayar bulunmamaktadır.\n\n")
+		}
+		for _, p := range presets {
+			sb.WriteString(htmlf("<b>%s</b>\n", p.Name))
+			sb.WriteString(htmlf("   %s / %s / %s\n", p.UTMSource, p.UTMMedium, p.UTMCampaign))
+			sb.WriteString(htmlf("   <code>%s</code>\n\n", presetDeepLink(bot, p.Name)))
+		}
+		sb.WriteString("<i>Linki açan kişi sadece URL ve kreatif adını girer.</i>")
+
+		msg := tgbotapi.NewMessage(chatID, sb.String())
+		msg.ParseMode = "HTML"
+		msg.DisableWebPagePreview = true
+		bot.Send(msg)
+		return
+	}
+
+	if !isAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Hazır ayarları sadece yöneticiler değiştirebilir.")
+		bot.Send(msg)
+		return
+	}
+
+	usage := "⚠️ Kullanım:\n/preset — Listele\n/preset ekle <ad> <source> <medium> <campaign>\n/preset sil <ad>\n\nÖrnek: /preset ekle ramazan meta paid_social ramazan_iftar"
+
+	switch {
+	case fields[0] == "ekle" && len(fields) == 5:
+		preset := UTMPreset{
+			Name:        sanitizeUTMValue(fields[1]),
+			UTMSource:   sanitizeUTMValue(fields[2]),
+			UTMMedium:   sanitizeUTMValue(fields[3]),
+			UTMCampaign: sanitizeUTMValue(fields[4]),
+			CreatedBy:   userID,
+		}
+		if !presetNameRegex.MatchString(preset.Name) {
+			msg := tgbotapi.NewMessage(chatID, "⚠️ Hazır ayar adı sadece küçük harf, rakam, _ ve - içerebilir (en fazla 50 karakter).")
+			bot.Send(msg)
+			return
+		}
+
+		_, err := db.NewInsert().
+			Model(&preset).
+			On("CONFLICT (name) DO UPDATE").
+			Set("utm_source = EXCLUDED.utm_source").
+			Set("utm_medium = EXCLUDED.utm_medium").
+			Set("utm_campaign = EXCLUDED.utm_campaign").
+			Set("created_by = EXCLUDED.created_by").
+			Exec(ctx)
+		if err != nil {
+			log.Printf("Hazır ayar kayıt hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+
+		msg := tgbotapi.NewMessage(chatID, htmlf("✅ <b>%s</b> hazır ayarı kaydedildi.\n\n🔗 Paylaşım linki:\n<code>%s</code>", preset.Name, presetDeepLink(bot, preset.Name)))
+		msg.ParseMode = "HTML"
+		msg.DisableWebPagePreview = true
+		bot.Send(msg)
+
+	case fields[0] == "sil" && len(fields) == 2:
+		res, err := db.NewDelete().Model((*UTMPreset)(nil)).Where("name = ?", fields[1]).Exec(ctx)
+		if err != nil {
+			log.Printf("Hazır ayar silme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			msg := tgbotapi.NewMessage(chatID, "⚠️ Bu isimde hazır ayar bulunamadı.")
+			bot.Send(msg)
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑️ %s hazır ayarı silindi. Paylaşılmış linkler artık çalışmayacak.", fields[1]))
+		bot.Send(msg)
+
+	default:
+		msg := tgbotapi.NewMessage(chatID, usage)
+		bot.Send(msg)
+	}
+}
+
+// periodTotal dönem karşılaştırmasında bir boyut değerinin bir para birimindeki toplamını tutar
+type periodTotal struct {
+	Name     string  `bun:"name"`
+	Currency string  `bun:"currency"`
+	Total    float64 `bun:"total"`
+	Count    int     `bun:"count"`
+}
+
+// queryDimensionPeriod UTM boyutu için para birimi bazında dönem toplamlarını döner
+func queryDimensionPeriod(ctx context.Context, scope DataScope, column string, startDate, endDate time.Time) ([]periodTotal, error) {
+	inner := db.NewSelect().
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("currency").
+		ColumnExpr("SUM(amount) as total").
+		ColumnExpr("COUNT(*) as count").
+		Where("event_time >= ?", startDate).
+		Where("event_time <= ?", endDate).
+		GroupExpr("currency")
+
+	var rows []periodTotal
+	err := db.NewSelect().
+		TableExpr("(?) AS r", dimensionTotalsQuery(inner, column, "currency", "total", "count")).
+		ColumnExpr(fmt.Sprintf("r.%s AS name", column)).
+		ColumnExpr("r.currency").
+		ColumnExpr("r.total").
+		ColumnExpr("r.count").
+		Scan(ctx, &rows)
+	return rows, err
+}
+
+// queryItemPeriod bağış kalemleri için para birimi bazında dönem toplamlarını döner
+func queryItemPeriod(ctx context.Context, scope DataScope, startDate, endDate time.Time) ([]periodTotal, error) {
+	var rows []periodTotal
+	err := db.NewRaw(`
+		SELECT 
+			oi.item_name as name,
+			o.currency,
+			SUM(oi.price * oi.quantity) as total,
+			SUM(oi.quantity)::int as count
+		FROM order_items oi JOIN (?) o ON o.id = oi.order_pk
+		WHERE o.event_time >= ? AND o.event_time <= ?
+		GROUP BY oi.item_name, o.currency
+	`, scope.orders(), startDate, endDate).Scan(ctx, &rows)
+	return rows, err
+}
+
+// parsePeriodPair "<aralık1> vs <aralık2>" ifadesini iki tarih aralığına ayırır
+func parsePeriodPair(args string) (start1, end1, start2, end2 time.Time, ok bool) {
+	left, right, found := strings.Cut(strings.ToLower(args), " vs ")
+	if !found {
+		return
+	}
+	var ok1, ok2 bool
+	start1, end1, ok1 = parseDateRange(left)
+	start2, end2, ok2 = parseDateRange(right)
+	ok = ok1 && ok2
+	return
+}
+
+// writePeriodDiffSheet iki dönemi yan yana, mutlak ve yüzde değişimle sheet'e yazar
+// Farklı para birimleri birbirine eklenmez: satırlar para birimine göre gruplanır ve her para birimi kendi toplam satırını alır
+func writePeriodDiffSheet(f *excelize.File, sheetName, dimensionTitle, period1Label, period2Label string, period1, period2 []periodTotal, headerStyle, dataStyle, amountStyle, percentStyle int) {
+	f.NewSheet(sheetName)
+
+	headers := []string{dimensionTitle, "Para Birimi", period1Label + " Tutar", period1Label + " Adet", period2Label + " Tutar", period2Label + " Adet", "Fark (Tutar)", "Değişim %"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, h)
+		f.SetCellStyle(sheetName, cell, cell, headerStyle)
+	}
+
+	// İki dönemin değerlerini isim ve para birimi bazında birleştir
+	type diffRow struct {
+		Name, Currency string
+		Total1, Total2 float64
+		Count1, Count2 int
+	}
+	rowMap := make(map[bucketKey]*diffRow)
+	var currencies moneyTotals
+	for _, p := range period1 {
+		rowMap[bucketKey{Name: p.Name, Currency: p.Currency}] = &diffRow{Name: p.Name, Currency: p.Currency, Total1: p.Total, Count1: p.Count}
+		currencies.add(p.Currency, 0, p.Count)
+	}
+	for _, p := range period2 {
+		key := bucketKey{Name: p.Name, Currency: p.Currency}
+		r, exists := rowMap[key]
+		if !exists {
+			r = &diffRow{Name: p.Name, Currency: p.Currency}
+			rowMap[key] = r
+		}
+		r.Total2 = p.Total
+		r.Count2 = p.Count
+		currencies.add(p.Currency, 0, p.Count)
+	}
+	currencies.sort()
+
+	rows := make([]*diffRow, 0, len(rowMap))
+	for _, r := range rowMap {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Currency != rows[j].Currency {
+			return currencies.rank(rows[i].Currency) < currencies.rank(rows[j].Currency)
+		}
+		if rows[i].Total2 != rows[j].Total2 {
+			return rows[i].Total2 > rows[j].Total2
+		}
+		return rows[i].Total1 > rows[j].Total1
+	})
+
+	currencyStyles := map[int]int{2: amountStyle}
+	writeRow := func(row int, name string, currency string, t1 float64, c1 int, t2 float64, c2 int) {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), name)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), currency)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), t1)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), c1)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), t2)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), c2)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), t2-t1)
+		switch {
+		case t1 != 0:
+			f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), (t2-t1)/t1)
+		case t2 != 0:
+			f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), "Yeni")
+		default:
+			f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), "—")
+		}
+
+		decimals := currencyDecimalPlaces(currency)
+		currencyStyle, ok := currencyStyles[decimals]
+		if !ok {
+			currencyStyle = newAmountStyle(f, decimals)
+			currencyStyles[decimals] = currencyStyle
+		}
+		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("B%d", row), dataStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("C%d", row), fmt.Sprintf("C%d", row), currencyStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("D%d", row), fmt.Sprintf("D%d", row), dataStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("E%d", row), fmt.Sprintf("E%d", row), currencyStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("F%d", row), fmt.Sprintf("F%d", row), dataStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("G%d", row), fmt.Sprintf("G%d", row), currencyStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("H%d", row), fmt.Sprintf("H%d", row), percentStyle)
+	}
+
+	row := 2
+	for i, r := range rows {
+		writeRow(row, r.Name, r.Currency, r.Total1, r.Count1, r.Total2, r.Count2)
+		row++
+
+		// Para biriminin son satırından sonra toplam satırı
+		if i == len(rows)-1 || rows[i+1].Currency != r.Currency {
+			var sum1, sum2 float64
+			var cnt1, cnt2 int
+			for _, cr := range rows {
+				if cr.Currency == r.Currency {
+					sum1 += cr.Total1
+					sum2 += cr.Total2
+					cnt1 += cr.Count1
+					cnt2 += cr.Count2
+				}
+			}
+			writeRow(row, "TOPLAM", r.Currency, sum1, cnt1, sum2, cnt2)
+			f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), headerStyle)
+			row++
+		}
+	}
+
+	f.SetColWidth(sheetName, "A", "A", 35)
+	f.SetColWidth(sheetName, "B", "B", 12)
+	f.SetColWidth(sheetName, "C", "G", 18)
+	f.SetColWidth(sheetName, "H", "H", 12)
+}
+
+// handleExportDiff /export fark komutunu işler - İki dönemi karşılaştıran Excel (dosya gönderildiyse true döner)
+func handleExportDiff(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) bool {
+	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
+
+	start1, end1, start2, end2, ok := parsePeriodPair(args)
+	if !ok {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Kullanım:\n/export fark DD.MM.YYYY - DD.MM.YYYY vs DD.MM.YYYY - DD.MM.YYYY\n\nÖrnek:\n/export fark 01.02.2025 - 28.02.2025 vs 01.03.2025 - 31.03.2025")
+		bot.Send(msg)
+		return false
+	}
+
+	period1Label := fmt.Sprintf("%s-%s", start1.Format("02.01"), end1.Format("02.01.2006"))
+	period2Label := fmt.Sprintf("%s-%s", start2.Format("02.01"), end2.Format("02.01.2006"))
+
+	type dimensionSheet struct {
+		SheetName string
+		Title     string
+		Query     func(start, end time.Time) ([]periodTotal, error)
+	}
+	sheets := []dimensionSheet{
+		{SheetName: "Kaynak", Title: "UTM Source", Query: func(start, end time.Time) ([]periodTotal, error) {
+			return queryDimensionPeriod(ctx, scope, "utm_source", start, end)
+		}},
+		{SheetName: "Kampanya", Title: "UTM Campaign", Query: func(start, end time.Time) ([]periodTotal, error) {
+			return queryDimensionPeriod(ctx, scope, "utm_campaign", start, end)
+		}},
+		{SheetName: "Kalem", Title: "Bağış Kalemi", Query: func(start, end time.Time) ([]periodTotal, error) {
+			return queryItemPeriod(ctx, scope, start, end)
+		}},
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headerStyle, dataStyle, amountStyle, percentStyle := createExportStyles(f)
+
+	var total1, total2 moneyTotals
+	for i, sh := range sheets {
+		period1, err1 := sh.Query(start1, end1)
+		period2, err2 := sh.Query(start2, end2)
+		if err := errors.Join(err1, err2); err != nil {
+			log.Printf("Dönem karşılaştırma sorgu hatası (%s): %v", sh.SheetName, err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return false
+		}
+
+		writePeriodDiffSheet(f, sh.SheetName, sh.Title, period1Label, period2Label, period1, period2, headerStyle, dataStyle, amountStyle, percentStyle)
+
+		// Genel toplamları kaynak sheet'inden al (her sipariş tek kaynağa aittir)
+		if i == 0 {
+			for _, p := range period1 {
+				total1.add(p.Currency, p.Total, p.Count)
+			}
+			for _, p := range period2 {
+				total2.add(p.Currency, p.Total, p.Count)
+			}
+		}
+	}
+	f.DeleteSheet("Sheet1")
+
+	filename := fmt.Sprintf("bagis_karsilastirma_%s_%s.xlsx", start1.Format("02-01-2006"), start2.Format("02-01-2006"))
+	filepath := fmt.Sprintf("/tmp/%s", filename)
+	if err := f.SaveAs(filepath); err != nil {
+		log.Printf("Excel kayıt hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Excel dosyası oluşturulamadı.")
+		bot.Send(msg)
+		return false
+	}
+	defer os.Remove(filepath)
+
+	// Değişim para birimi bazında hesaplanır
+	total1.sort()
+	total2.sort()
+	currencies := append(moneyTotals(nil), total1...)
+	for _, ct := range total2 {
+		currencies.add(ct.Currency, ct.Total, ct.Count)
+	}
+	currencies.sort()
+
+	var changes []string
+	for _, ct := range currencies {
+		var t1, t2 float64
+		if i := total1.rank(ct.Currency); i < len(total1) {
+			t1 = total1[i].Total
+		}
+		if i := total2.rank(ct.Currency); i < len(total2) {
+			t2 = total2[i].Total
+		}
+		change := "—"
+		if t1 != 0 {
+			change = fmt.Sprintf("%%%+.1f", (t2-t1)/t1*100)
+		} else if t2 != 0 {
+			change = "Yeni"
+		}
+		if len(currencies) > 1 {
+			change = ct.Currency + " " + change
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "—")
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
+	doc.Caption = fmt.Sprintf("📊 Dönem Karşılaştırması\n\n1️⃣ %s: %s\n2️⃣ %s: %s\n📈 Değişim: %s\n\n📑 Sayfalar: Kaynak, Kampanya, Kalem",
+		period1Label, total1.String(), period2Label, total2.String(), strings.Join(changes, " | "))
+
+	if _, err := bot.Send(doc); err != nil {
+		log.Printf("Dosya gönderme hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Dosya gönderilemedi.")
+		bot.Send(msg)
+		return false
+	}
+	return true
+}
+
+// scheduledJob periyodik olarak çalıştırılan işi tanımlar
+type scheduledJob struct {
+	Name      string
+	Interval  time.Duration
+	Run       func(ctx context.Context) error
+	Singleton bool // Birden fazla örnek çalışırken sadece lider örnekte çalışır
+}
+
+// todayReconcileJob bugünün read-model'ini veritabanıyla uzlaştıran işi döner
+func todayReconcileJob() scheduledJob {
+	reconcileInterval, err := time.ParseDuration(getEnv("TODAY_RECONCILE_INTERVAL", "5m"))
+	if err != nil || reconcileInterval <= 0 {
+		log.Printf("UYARI: TODAY_RECONCILE_INTERVAL geçersiz, 5m kullanılacak: %v", err)
+		reconcileInterval = 5 * time.Minute
+	}
+	// İlk çalıştırma başlangıçta bugünün read-model'ini oluşturur, sonrakiler DB ile uzlaştırır
+	return scheduledJob{Name: "today-reconcile", Interval: reconcileInterval, Run: rebuildTodayAggregate}
+}
+
+// getScheduledJobs çalışma moduna ve yapılandırmaya göre çalıştırılacak periyodik işleri döner
+func getScheduledJobs(mode runMode) []scheduledJob {
+	var jobs []scheduledJob
+
+	// Bugünün read-model'i süreç belleğinde tutulur, /gunluk'u cevaplayan bot sürecinde çalışmalı
+	if mode.Bot {
+		jobs = append(jobs, todayReconcileJob())
+	}
+
+	// Her rol kendi heartbeat'ini gönderir (bkz. getHeartbeatTargets)
+	for _, target := range getHeartbeatTargets(mode) {
+		if os.Getenv(target.URLEnv) == "" {
+			log.Printf("%s ayarlanmamış, %s ping'i gönderilmeyecek", target.URLEnv, target.Name)
+			continue
+		}
+		interval, err := time.ParseDuration(getEnv("HEARTBEAT_INTERVAL", "1m"))
+		if err != nil || interval <= 0 {
+			log.Printf("UYARI: HEARTBEAT_INTERVAL geçersiz, 1m kullanılacak: %v", err)
+			interval = time.Minute
+		}
+		heartbeat := func(ctx context.Context) error { return runHeartbeat(ctx, target) }
+		jobs = append(jobs, scheduledJob{Name: target.Name, Interval: interval, Run: heartbeat, Singleton: target.Singleton})
+	}
+
+	return jobs
+}
+
+// heartbeatTarget bir rolün harici izleme ping'ini tanımlar
+type heartbeatTarget struct {
+	Name       string // Zamanlanmış iş adı
+	URLEnv     string // Sağlıklıyken ping atılan URL'in environment variable'ı
+	FailURLEnv string // Sağlıksızken ping atılan URL'in environment variable'ı
+	Singleton  bool   // Sadece scheduler liderinde çalışır
+	Check      func(ctx context.Context) error
+}
+
+// getHeartbeatTargets çalışma moduna göre gönderilecek heartbeat'leri döner.
+// Ayrık modda her rol kendi URL'ine ping atar; böylece poller'ın veya tüm API örneklerinin
+// durması da izleme servisinde eksik ping olarak görünür.
+func getHeartbeatTargets(mode runMode) []heartbeatTarget {
+	if mode.all() {
+		// Tek süreç her şeyi çalıştırır, tüm kontroller tek heartbeat'te birleşir
+		return []heartbeatTarget{{
+			Name: "heartbeat", URLEnv: "HEARTBEAT_URL", FailURLEnv: "HEARTBEAT_FAIL_URL", Singleton: true,
+			Check: func(ctx context.Context) error {
+				if err := checkIngestHealth(ctx, true); err != nil {
+					return err
+				}
+				return checkPollerHealth()
+			},
+		}}
+	}
+
+	var targets []heartbeatTarget
+	if mode.Worker {
+		// Worker /throw-data karşılamaz, sadece veritabanını kontrol eder
+		targets = append(targets, heartbeatTarget{
+			Name: "heartbeat", URLEnv: "HEARTBEAT_URL", FailURLEnv: "HEARTBEAT_FAIL_URL", Singleton: true,
+			Check: func(ctx context.Context) error { return checkIngestHealth(ctx, false) },
+		})
+	}
+	if mode.API {
+		// Her API örneği kendi kayıt sayacını bildirir; hepsi durursa ping kesilir
+		targets = append(targets, heartbeatTarget{
+			Name: "heartbeat-api", URLEnv: "HEARTBEAT_URL_API", FailURLEnv: "HEARTBEAT_FAIL_URL_API",
+			Check: func(ctx context.Context) error { return checkIngestHealth(ctx, true) },
+		})
+	}
+	if mode.Bot {
+		// Sadece poller'ı çalıştıran lider örnek ping atar
+		targets = append(targets, heartbeatTarget{
+			Name: "heartbeat-bot", URLEnv: "HEARTBEAT_URL_BOT", FailURLEnv: "HEARTBEAT_FAIL_URL_BOT",
+			Check: func(ctx context.Context) error { return checkPollerHealth() },
+		})
+	}
+	return targets
+}
+
+// startScheduler periyodik işleri ayrı goroutine'lerde başlatır (ilk çalıştırma hemen yapılır)
+// Tekil işler, scheduler liderliği alındıktan sonra başlar (diğer örnekler beklemede kalır)
+func startScheduler(jobs []scheduledJob) {
+	var singletons []scheduledJob
+	for _, job := range jobs {
+		if job.Singleton {
+			singletons = append(singletons, job)
+			continue
+		}
+		go runScheduledJob(context.Background(), job)
+	}
+
+	if len(singletons) > 0 {
+		go func() {
+			// Liderlik düşerse tekil işler durur ve kilit yeniden beklenir
+			for {
+				leaderCtx := acquireLeadership("scheduler")
+				var wg sync.WaitGroup
+				for _, job := range singletons {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						runScheduledJob(leaderCtx, job)
+					}()
+				}
+				wg.Wait()
+			}
+		}()
+	}
+}
+
+// runScheduledJob işi ctx iptal edilene kadar aralıklarla çalıştırır
+func runScheduledJob(ctx context.Context, job scheduledJob) {
+	log.Printf("Zamanlanmış iş başlatıldı: %s (her %s)", job.Name, job.Interval)
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, job.Interval)
+		if err := job.Run(runCtx); err != nil {
+			log.Printf("Zamanlanmış iş hatası (%s): %v", job.Name, err)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Zamanlanmış iş durduruldu: %s", job.Name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ingestion watchdog durumu
+var (
+	lastIngestAt              atomic.Int64 // Son başarılı kaydın unix zamanı
+	consecutiveIngestFailures atomic.Int64 // Art arda başarısız kayıt sayısı (sadece başarılı kayıt sıfırlar)
+	processStartedAt          = time.Now() // Henüz kayıt yokken durgunluk bu andan ölçülür
+)
+
+// recordIngestResult /throw-data kayıt sonucunu watchdog için işler
+// Tekrarlanan sipariş gibi veri kaynaklı hatalar servis hatası sayılmaz
+func recordIngestResult(err error) {
+	if err == nil {
+		lastIngestAt.Store(time.Now().Unix())
+		consecutiveIngestFailures.Store(0)
+		return
+	}
+
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) && pgErr.IntegrityViolation() {
+		return
+	}
+	consecutiveIngestFailures.Add(1)
+}
+
+// checkIngestHealth ingestion hattının sağlıklı olup olmadığını kontrol eder
+// trackIngest false ise (API'siz süreç, örn. --mode=worker) sadece veritabanı kontrol edilir
+func checkIngestHealth(ctx context.Context, trackIngest bool) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("veritabanına erişilemiyor: %w", err)
+	}
+	// Sayaç süreç içidir; /throw-data'yı bu süreç karşılamıyorsa hiç artmaz, API örnekleri kendi heartbeat'lerinde kontrol eder
+	if !trackIngest {
+		return nil
+	}
+	// Sayaç sadece başarılı bir kayıtla sıfırlanır; seyrek trafikte kalıcı hata da alarm üretir
+	if failures := consecutiveIngestFailures.Load(); failures >= 3 {
+		return fmt.Errorf("art arda %d sipariş kaydedilemedi", failures)
+	}
+	// İsteğe bağlı durgunluk kontrolü: bağış hiç gelmiyorsa (site/webhook kopukluğu) da alarm üretilir
+	if staleAfter, err := time.ParseDuration(os.Getenv("INGEST_STALE_AFTER")); err == nil && staleAfter > 0 {
+		last := processStartedAt
+		if ts := lastIngestAt.Load(); ts > 0 {
+			last = time.Unix(ts, 0)
+		}
+		if since := time.Since(last); since > staleAfter {
+			return fmt.Errorf("son %s içinde sipariş kaydedilmedi", since.Truncate(time.Minute))
+		}
+	}
+	return nil
+}
+
+var (
+	pollerActive atomic.Bool  // Bu süreç şu an Telegram poller'ını çalıştırıyor (lider)
+	lastPollAt   atomic.Int64 // Son başarılı GetUpdates çağrısının unix zamanı
+)
+
+// Poller bu süreden uzun süredir güncelleme alamıyorsa sağlıksız sayılır (long polling süresinin birkaç katı)
+const pollerStaleAfter = 3 * time.Minute
+
+// errHeartbeatStandby yedekteki bot örneği ping atmaz, izleme servisi lider örneğin ping'ini bekler
+var errHeartbeatStandby = errors.New("bot örneği yedekte")
+
+// checkPollerHealth Telegram poller'ının güncelleme alabildiğini kontrol eder
+func checkPollerHealth() error {
+	if !pollerActive.Load() {
+		return errHeartbeatStandby
+	}
+	if since := time.Since(time.Unix(lastPollAt.Load(), 0)); since > pollerStaleAfter {
+		return fmt.Errorf("Telegram güncellemeleri %s süredir alınamıyor", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+var heartbeatClient = &http.Client{Timeout: 10 * time.Second}
+
+// runHeartbeat watchdog kontrolünü çalıştırır ve sonucu harici izleme servisine bildirir
+// Sağlıklıysa rolün URL'ine, değilse (tanımlıysa) fail URL'ine ping atılır
+func runHeartbeat(ctx context.Context, target heartbeatTarget) error {
+	pingURL := os.Getenv(target.URLEnv)
+
+	if healthErr := target.Check(ctx); healthErr != nil {
+		if errors.Is(healthErr, errHeartbeatStandby) {
+			return nil
+		}
+		log.Printf("Watchdog (%s): sağlık kontrolü başarısız: %v", target.Name, healthErr)
+		pingURL = os.Getenv(target.FailURLEnv)
+		if pingURL == "" {
+			// Fail URL yoksa ping atlanır, izleme servisi eksik ping'den alarm üretir
+			return nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL, nil)
+	if err != nil {
+		return fmt.Errorf("heartbeat isteği oluşturulamadı: %w", err)
+	}
+
+	resp, err := heartbeatClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("heartbeat gönderilemedi: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat beklenmeyen yanıt: %s", resp.Status)
+	}
+	return nil
+}
+
+// aggregateBucket read-model'de bir grubun bir para birimindeki toplamını tutar
+type aggregateBucket struct {
+	Name     string
+	Currency string
+	Total    float64
+	Count    int
+}
+
+// bucketKey read-model gruplarının anahtarı (aynı kaynağın farklı para birimleri ayrı tutulur)
+type bucketKey struct {
+	Name     string
+	Currency string
+}
+
+// todayAggregate bugünün (Türkiye saati) kaynak/kampanya bazlı toplamlarını bellekte tutar
+type todayAggregate struct {
+	Day       string // 2006-01-02 (Türkiye saati)
+	Totals    moneyTotals
+	Count     int
+	Sources   map[bucketKey]*aggregateBucket
+	Campaigns map[bucketKey]*aggregateBucket
+	BuiltAt   time.Time // Veritabanından son oluşturulma zamanı
+	MaxID     int64     // Oluşturma sorgusunun içerdiği en büyük sipariş ID'si (bu ve altı zaten sayıldı)
+}
+
+// todaySnapshot read-model'in sıralanmış anlık görüntüsü
+type todaySnapshot struct {
+	Day       string
+	Totals    moneyTotals // En çok bağış alan para birimi önce
+	Count     int
+	Sources   []aggregateBucket // Para birimine göre gruplu, toplama göre azalan
+	Campaigns []aggregateBucket // Para birimine göre gruplu, toplama göre azalan
+}
+
+var today *todayAggregate
+var todayMutex sync.RWMutex
+
+// todayAddedOrder yeniden oluşturma sırasında read-model'e eklenen sipariş (swap sonrası yeni modele aktarılır)
+type todayAddedOrder struct {
+	ID       int64
+	Day      string
+	Source   string
+	Campaign string
+	Currency string
+	Amount   float64
+}
+
+// Devam eden yeniden oluşturma sayısı ve bu sürede eklenen siparişler (todayMutex ile korunur)
+var (
+	todayRebuilds       int
+	todayAddedInRebuild []todayAddedOrder
+)
+
+// Ingest ayrı süreçteyken (--mode=bot) read-model'in veritabanından yenilenmeden kullanılabileceği süre
+const todaySnapshotMaxAge = 30 * time.Second
+
+// newTodayAggregate verilen gün için boş read-model oluşturur
+func newTodayAggregate(day string) *todayAggregate {
+	return &todayAggregate{
+		Day:       day,
+		Sources:   make(map[bucketKey]*aggregateBucket),
+		Campaigns: make(map[bucketKey]*aggregateBucket),
+	}
+}
+
+// add read-model'e bir grup toplamı ekler
+func (a *todayAggregate) add(source, campaign, currency string, total float64, count int) {
+	a.Totals.add(currency, total, count)
+	a.Count += count
+
+	sourceKey := bucketKey{Name: source, Currency: currency}
+	if a.Sources[sourceKey] == nil {
+		a.Sources[sourceKey] = &aggregateBucket{Name: source, Currency: currency}
+	}
+	a.Sources[sourceKey].Total += total
+	a.Sources[sourceKey].Count += count
+
+	campaignKey := bucketKey{Name: campaign, Currency: currency}
+	if a.Campaigns[campaignKey] == nil {
+		a.Campaigns[campaignKey] = &aggregateBucket{Name: campaign, Currency: currency}
+	}
+	a.Campaigns[campaignKey].Total += total
+	a.Campaigns[campaignKey].Count += count
+}
+
+// orderSourceLabel /gunluk raporundaki kaynak etiketini döner (utm_source > Google Ads > Doğrudan)
+func orderSourceLabel(utmSource, trafficChannel string) string {
+	if utmSource != "" {
+		return utmSource
+	}
+	if trafficChannel == "google" {
+		return "Google Ads"
+	}
+	return "Doğrudan"
+}
+
+// orderCampaignLabel read-model'deki kampanya etiketini döner
+func orderCampaignLabel(utmCampaign string) string {
+	if utmCampaign == "" {
+		return "Belirtilmemiş"
+	}
+	return utmCampaign
+}
+
+// turkeyDayKey zamanın Türkiye saatine göre gün anahtarını döner
+func turkeyDayKey(t time.Time) string {
+	return t.In(getTurkeyLocation()).Format("2006-01-02")
+}
+
+// buildTodayAggregate bugünün toplamlarını veritabanından kapsama göre hesaplar
+func buildTodayAggregate(ctx context.Context, scope DataScope) (*todayAggregate, error) {
+	return buildDayAggregate(ctx, scope, getTurkeyNow())
+}
+
+// buildDayAggregate verilen günün (Türkiye saati) kaynak/kampanya toplamlarını veritabanından hesaplar
+func buildDayAggregate(ctx context.Context, scope DataScope, day time.Time) (*todayAggregate, error) {
+	startOfDayUTC, endOfDayUTC := dayRangeUTC(day)
+
+	var groups []struct {
+		UTMSource      string  `bun:"utm_source"`
+		TrafficChannel string  `bun:"traffic_channel"`
+		UTMCampaign    string  `bun:"utm_campaign"`
+		Currency       string  `bun:"currency"`
+		Total          float64 `bun:"total"`
+		Count          int     `bun:"count"`
+		MaxID          int64   `bun:"max_id"`
+	}
+	err := db.NewSelect().
+		TableExpr("(?) AS orders", scope.orders()).
+		ColumnExpr("COALESCE(utm_source, '') as utm_source").
+		ColumnExpr("COALESCE(traffic_channel, '') as traffic_channel").
+		ColumnExpr("COALESCE(utm_campaign, '') as utm_campaign").
+		ColumnExpr("currency").
+		ColumnExpr("SUM(amount) as total").
+		ColumnExpr("COUNT(*) as count").
+		ColumnExpr("MAX(id) as max_id").
+		Where("event_time >= ?", startOfDayUTC).
+		Where("event_time < ?", endOfDayUTC).
+		GroupExpr("utm_source_id, utm_campaign_id, 1, 2, 3, 4").
+		Scan(ctx, &groups)
+	if err != nil {
+		return nil, fmt.Errorf("günlük read-model sorgusu başarısız: %w", err)
+	}
+
+	aggregate := newTodayAggregate(turkeyDayKey(day))
+	aggregate.BuiltAt = time.Now()
+	for _, g := range groups {
+		aggregate.add(orderSourceLabel(g.UTMSource, g.TrafficChannel), orderCampaignLabel(g.UTMCampaign), g.Currency, g.Total, g.Count)
+		aggregate.MaxID = max(aggregate.MaxID, g.MaxID)
+	}
+	return aggregate, nil
+}
+
+// rebuildTodayAggregate bugünün read-model'ini veritabanından yeniden oluşturur (başlangıç + periyodik uzlaştırma)
+// Sorgu sürerken eklenen siparişler eski modelle kaybolmasın diye kaydedilir ve sorgunun görmediği
+// (MaxID'den büyük) olanlar yeni modele aktarılır; sorgunun zaten saydıkları ikinci kez eklenmez
+func rebuildTodayAggregate(ctx context.Context) error {
+	todayMutex.Lock()
+	todayRebuilds++
+	startIndex := len(todayAddedInRebuild)
+	todayMutex.Unlock()
+
+	fresh, err := buildTodayAggregate(ctx, DataScope{})
+
+	todayMutex.Lock()
+	defer todayMutex.Unlock()
+
+	added := todayAddedInRebuild[startIndex:]
+	todayRebuilds--
+	if todayRebuilds == 0 {
+		todayAddedInRebuild = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, o := range added {
+		if o.Day == fresh.Day && o.ID > fresh.MaxID {
+			fresh.add(o.Source, o.Campaign, o.Currency, o.Amount, 1)
+		}
+	}
+	if today != nil && today.Day == fresh.Day && today.Count != fresh.Count {
+		log.Printf("Günlük read-model uzlaştırıldı: bellek=%d bağış, veritabanı=%d bağış", today.Count, fresh.Count)
+	}
+	today = fresh
+	return nil
+}
+
+// invalidateTodayAggregate read-model'i geçersiz kılar, bir sonraki okuma veritabanından oluşturur (toplu silme sonrası)
+func invalidateTodayAggregate() {
+	todayMutex.Lock()
+	today = nil
+	todayMutex.Unlock()
+}
+
+// addToTodayAggregate yeni kaydedilen siparişi read-model'e ekler (gün değiştiyse sıfırlar)
+func addToTodayAggregate(order *Order) {
+	day := turkeyDayKey(order.EventTime)
+	currentDay := turkeyDayKey(time.Now())
+	if day != currentDay {
+		return
+	}
+
+	entry := todayAddedOrder{
+		ID:       order.ID,
+		Day:      day,
+		Source:   orderSourceLabel(order.UTMSource, order.TrafficChannel),
+		Campaign: orderCampaignLabel(order.UTMCampaign),
+		Currency: order.Currency,
+		Amount:   order.Amount,
+	}
+
+	todayMutex.Lock()
+	defer todayMutex.Unlock()
+
+	if todayRebuilds > 0 {
+		todayAddedInRebuild = append(todayAddedInRebuild, entry)
+	}
+	if today == nil {
+		// Henüz oluşturulmadı, ilk uzlaştırma bu siparişi de içerecek
+		return
+	}
+	if today.Day != currentDay {
+		today = newTodayAggregate(currentDay)
+	}
+	// Yeniden oluşturma sorgusu bu siparişi zaten saydıysa tekrar ekleme
+	if entry.ID <= today.MaxID {
+		return
+	}
+	today.add(entry.Source, entry.Campaign, entry.Currency, entry.Amount, 1)
+}
+
+// sortedBuckets grupları para birimi sırasına (totals), para birimi içinde toplama göre azalan sırada döner
+func sortedBuckets(m map[bucketKey]*aggregateBucket, totals moneyTotals) []aggregateBucket {
+	buckets := make([]aggregateBucket, 0, len(m))
+	for _, b := range m {
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Currency != buckets[j].Currency {
+			return totals.rank(buckets[i].Currency) < totals.rank(buckets[j].Currency)
+		}
+		return buckets[i].Total > buckets[j].Total
+	})
+	return buckets
+}
+
+// snapshot read-model'in sıralanmış kopyasını döner
+func (a *todayAggregate) snapshot() todaySnapshot {
+	totals := append(moneyTotals(nil), a.Totals...)
+	totals.sort()
+	return todaySnapshot{
+		Day:       a.Day,
+		Totals:    totals,
+		Count:     a.Count,
+		Sources:   sortedBuckets(a.Sources, totals),
+		Campaigns: sortedBuckets(a.Campaigns, totals),
+	}
+}
+
+// getTodaySnapshot bugünün read-model görüntüsünü döner, hazır değilse veritabanından oluşturur
+// Kapsamlı sohbetler paylaşılan read-model'i kullanamaz, toplamlar kapsama göre DB'den hesaplanır
+func getTodaySnapshot(ctx context.Context, scope DataScope) (todaySnapshot, error) {
+	if scope.isScoped() {
+		aggregate, err := buildTodayAggregate(ctx, scope)
+		if err != nil {
+			return todaySnapshot{}, err
+		}
+		return aggregate.snapshot(), nil
+	}
+
+	currentDay := turkeyDayKey(time.Now())
+
+	todayMutex.RLock()
+	ready := today != nil
+	stale := ready && today.Day != currentDay
+	// Ingest başka süreçteyse yeni bağışlar belleğe düşmez, kısa aralıklarla DB'den yenilenir
+	if ready && !ingestInProcess && time.Since(today.BuiltAt) > todaySnapshotMaxAge {
+		stale = true
+	}
+	todayMutex.RUnlock()
+
+	if !ready || stale {
+		if err := rebuildTodayAggregate(ctx); err != nil {
+			return todaySnapshot{}, err
+		}
+	}
+
+	todayMutex.RLock()
+	defer todayMutex.RUnlock()
+	return today.snapshot(), nil
+}
+
+// Bildirim şablonları
+const (
+	notificationTemplateFull   = "tam"   // İç gruplar: sipariş ID, UTM ve Google Ads detayları dahil
+	notificationTemplatePublic = "genel" // Herkese açık kanallar: sipariş ID ve bağışçıya dair ipucu yok
+)
+
+// NotificationChatSetting bildirim hedefi için chat tipinden bağımsız şablon seçimini tutar
+type NotificationChatSetting struct {
+	bun.BaseModel `bun:"table:notification_chat_settings,alias:ncs"`
+
+	ChatID    int64     `bun:"chat_id,pk"`
+	Template  string    `bun:"template,notnull"`
+	UpdatedBy int64     `bun:"updated_by"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// chatTypes Telegram'dan alınan chat tiplerini önbellekte tutar (her bildirimde GetChat yapılmaz)
+var chatTypes = make(map[int64]string)
+var chatTypesMutex sync.RWMutex
+
+// getChatType chat tipini döner (private, group, supergroup, channel)
+func getChatType(bot *tgbotapi.BotAPI, chatID int64) (string, error) {
+	chatTypesMutex.RLock()
+	chatType, ok := chatTypes[chatID]
+	chatTypesMutex.RUnlock()
+	if ok {
+		return chatType, nil
+	}
+
+	chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		return "", err
+	}
+
+	chatTypesMutex.Lock()
+	chatTypes[chatID] = chat.Type
+	chatTypesMutex.Unlock()
+	return chat.Type, nil
+}
+
+// defaultNotificationTemplate chat tipine göre varsayılan şablonu döner
+func defaultNotificationTemplate(chatType string) string {
+	if chatType == "channel" {
+		return notificationTemplatePublic
+	}
+	return notificationTemplateFull
+}
+
+// resolveNotificationTemplate chat için kullanılacak şablonu döner (chat ayarı > chat tipi)
+func resolveNotificationTemplate(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) string {
+	setting := NotificationChatSetting{ChatID: chatID}
+	err := db.NewSelect().Model(&setting).WherePK().Scan(ctx)
+	if err == nil {
+		return setting.Template
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("Bildirim ayarı sorgu hatası (chat_id=%d): %v", chatID, err)
+	}
+
+	chatType, err := getChatType(bot, chatID)
+	if err != nil {
+		// Chat tipi bilinmiyorsa detay sızdırmamak için genel şablon kullanılır
+		log.Printf("Chat tipi alınamadı (chat_id=%d): %v", chatID, err)
+		return notificationTemplatePublic
+	}
+	return defaultNotificationTemplate(chatType)
+}
+
+// formatNotification siparişi seçilen şablona göre bildirim mesajına dönüştürür
+func formatNotification(req *ThrowDataRequest, template string, isHighDonation bool) string {
+	if template == notificationTemplatePublic {
+		return formatPublicOrderMessage(req, isHighDonation)
+	}
+	if isHighDonation {
+		return formatHighDonationMessage(req)
+	}
+	return formatOrderMessage(req)
+}
+
+// formatPublicOrderMessage herkese açık kanallar için bağış mesajı oluşturur (sipariş ID, UTM ve etiket yok)
+func formatPublicOrderMessage(req *ThrowDataRequest, isHighDonation bool) string {
+	var sb strings.Builder
+
+	// Türkiye saati için UTC+3 ekle
+	turkeyTime := req.EventTime.Add(3 * time.Hour)
+
+	if isHighDonation {
+		sb.WriteString("🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉\n")
+		sb.WriteString("💎 <b>Büyük Bir Bağış Geldi!</b> 💎\n")
+		sb.WriteString("🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉\n\n")
+	} else {
+		sb.WriteString("💚 <b>Yeni Bir Bağış Geldi!</b>\n\n")
+	}
+
+	sb.WriteString(htmlf("💰 <b>Tutar:</b> %s\n", formatMoney(req.Amount, req.Currency)))
+	sb.WriteString(htmlf("📅 <b>Tarih:</b> %s\n", turkeyTime.Format("02.01.2006 15:04")))
+
+	if len(req.Items) > 0 {
+		sb.WriteString("\n📦 <b>Bağış Kalemleri:</b>\n")
+		for _, item := range req.Items {
+			sb.WriteString(htmlf("  • %s\n", item.ItemName))
+		}
+	}
+
+	sb.WriteString("\n🤲 Allah kabul etsin!")
+	return sb.String()
+}
+
+// handleBildirimCommand /bildirim komutunu işler - Bildirim hedeflerinin şablonlarını listeler/değiştirir
+func handleBildirimCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	if !isAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Bu komut sadece yöneticiler tarafından kullanılabilir.")
+		bot.Send(msg)
+		return
+	}
+
+	ctx := context.Background()
+	fields := strings.Fields(args)
+
+	// Listeleme
+	if len(fields) == 0 {
+		var settings []NotificationChatSetting
+		if err := db.NewSelect().Model(&settings).Scan(ctx); err != nil {
+			log.Printf("Bildirim ayarı listeleme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		overrides := make(map[int64]string)
+		for _, s := range settings {
+			overrides[s.ChatID] = s.Template
+		}
+
+		var sb strings.Builder
+		sb.WriteString("🔔 <b>Bildirim Hedefleri</b>\n\n")
+		chatIDs := getNotificationChatIDs()
+		if len(chatIDs) == 0 {
+			sb.WriteString("ℹ️ NOTIFICATION_CHAT_IDS ayarlanmamış.\n\n")
+		}
+		for _, id := range chatIDs {
+			chatType, err := getChatType(bot, id)
+			if err != nil {
+				chatType = "bilinmiyor"
+			}
+			template := defaultNotificationTemplate(chatType)
+			source := "otomatik"
+			if override, ok := overrides[id]; ok {
+				template = override
+				source = "elle"
+			}
+			sb.WriteString(htmlf("<code>%d</code> (%s)\n", id, chatType))
+			sb.WriteString(htmlf("   Şablon: <b>%s</b> (%s)\n\n", template, source))
+		}
+		sb.WriteString("<i>Kanallar varsayılan olarak genel, gruplar tam şablonu kullanır.</i>\n")
+		sb.WriteString("<i>Değiştirmek için: /bildirim [chat_id] genel|tam|otomatik</i>")
+
+		msg := tgbotapi.NewMessage(chatID, sb.String())
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	// Chat ID verilmezse komutun yazıldığı chat için ayarlanır
+	targetChatID := chatID
+	if len(fields) == 2 {
+		id, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, "⚠️ Geçersiz chat ID.")
+			bot.Send(msg)
+			return
+		}
+		targetChatID = id
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Kullanım: /bildirim [chat_id] genel|tam|otomatik")
+		bot.Send(msg)
+		return
+	}
+
+	var text string
+	switch template := strings.ToLower(fields[0]); template {
+	case notificationTemplatePublic, notificationTemplateFull:
+		setting := &NotificationChatSetting{ChatID: targetChatID, Template: template, UpdatedBy: userID, UpdatedAt: time.Now()}
+		_, err := db.NewInsert().
+			Model(setting).
+			On("CONFLICT (chat_id) DO UPDATE").
+			Set("template = EXCLUDED.template").
+			Set("updated_by = EXCLUDED.updated_by").
+			Set("updated_at = EXCLUDED.updated_at").
+			Exec(ctx)
+		if err != nil {
+			log.Printf("Bildirim ayarı kayıt hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		text = htmlf("✅ <code>%d</code> için bildirim şablonu: <b>%s</b>", targetChatID, template)
+	case "otomatik":
+		_, err := db.NewDelete().Model((*NotificationChatSetting)(nil)).Where("chat_id = ?", targetChatID).Exec(ctx)
+		if err != nil {
+			log.Printf("Bildirim ayarı silme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		text = htmlf("✅ <code>%d</code> için şablon chat tipine göre otomatik seçilecek.", targetChatID)
+	default:
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Geçersiz şablon. Seçenekler: genel, tam, otomatik")
+		bot.Send(msg)
+		return
+	}
+
+	log.Printf("Bildirim şablonu değiştirildi: chat=%d, user=%d, %s", targetChatID, userID, fields[0])
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// Bulanık zaman aramasında tek saat verildiğinde (tarih=dun 14:30) öncesine ve sonrasına eklenen pay
+const fuzzyTimeWindow = 30 * time.Minute
+
+// Saat aralığı verildiğinde (tarih=dun 14:00-15:00) iki uca eklenen pay
+const fuzzyRangeSlack = 15 * time.Minute
+
+// Yaklaşık tutar aramasında (tutar~500) kabul edilen sapma oranı
+const fuzzyAmountRatio = 0.10
+
+var filterTimeRegex = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?:-(\d{1,2}):(\d{2}))?$`)
+
+// orderFilter /ara ve /sorgu için çözülmüş filtre koşullarını tutar
+type orderFilter struct {
+	conditions   []orderCondition
+	descriptions []string // Kullanıcıya gösterilen filtre özeti
+}
+
+// orderCondition tek bir WHERE koşulu
+type orderCondition struct {
+	query string
+	args  []interface{}
+}
+
+// orderTextFilters metin filtrelerinin anahtarlarını sipariş sütunlarına eşler
+var orderTextFilters = map[string]struct {
+	Column string
+	Title  string
+}{
+	"kaynak":   {Column: "o.utm_source", Title: "Kaynak"},
+	"ortam":    {Column: "o.utm_medium", Title: "Ortam"},
+	"kampanya": {Column: "o.utm_campaign", Title: "Kampanya"},
+	"icerik":   {Column: "o.utm_content", Title: "İçerik"},
+	"kanal":    {Column: "o.traffic_channel", Title: "Kanal"},
+	"id":       {Column: "o.order_id", Title: "Sipariş ID"},
+}
+
+// Filtre operatörleri (iki karakterliler önce denenir)
+var filterOperators = []string{">=", "<=", "!=", ">", "<", "=", "~"}
+
+// tokenizeFilter argümanları boşluklardan böler, tırnak içindeki boşlukları korur (kalem="Su Kuyusu")
+func tokenizeFilter(args string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range args {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case (r == ' ' || r == '\t' || r == '\n') && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// splitFilterToken "tutar>=500" ifadesini anahtar, operatör ve değere ayırır
+func splitFilterToken(token string) (key, op, value string, ok bool) {
+	i := strings.IndexAny(token, "<>=!~")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	for _, candidate := range filterOperators {
+		if strings.HasPrefix(token[i:], candidate) {
+			return strings.ToLower(token[:i]), candidate, token[i+len(candidate):], true
+		}
+	}
+	return "", "", "", false
+}
+
+// parseOrderFilter /ara ve /sorgu filtre dilini çözer
+// Örnek: tutar>500 tutar~250 tutar=100-200 tarih=dun 14:00-15:00 kaynak=meta kampanya~ramazan kalem="Su Kuyusu"
+func parseOrderFilter(args string) (orderFilter, error) {
+	var f orderFilter
+	tokens := tokenizeFilter(args)
+
+	for i := 0; i < len(tokens); i++ {
+		key, op, value, ok := splitFilterToken(tokens[i])
+		if !ok || value == "" {
+			return f, fmt.Errorf("anlaşılamayan filtre: %s", tokens[i])
+		}
+
+		switch key {
+		case "tutar":
+			if err := f.addAmount(op, value); err != nil {
+				return f, err
+			}
+		case "tarih":
+			if op != "=" {
+				return f, fmt.Errorf("tarih için sadece = kullanılabilir")
+			}
+			// Tarihten sonra gelen saat/saat aralığı aynı filtreye aittir (tarih=dun 14:00-15:00)
+			timeToken := ""
+			if i+1 < len(tokens) && filterTimeRegex.MatchString(tokens[i+1]) {
+				timeToken = tokens[i+1]
+				i++
+			}
+			if err := f.addDate(value, timeToken); err != nil {
+				return f, err
+			}
+		case "kalem":
+			if op != "=" && op != "~" {
+				return f, fmt.Errorf("kalem için = veya ~ kullanılabilir")
+			}
+			// Diğer metin filtreleri gibi = tam eşleşme (büyük/küçük harf duyarsız), ~ içerir
+			if op == "=" {
+				f.conditions = append(f.conditions, orderCondition{
+					query: "EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_pk = o.id AND LOWER(oi.item_name) = LOWER(?))",
+					args:  []interface{}{value},
+				})
+				f.descriptions = append(f.descriptions, fmt.Sprintf("Kalem: %s", value))
+			} else {
+				f.conditions = append(f.conditions, orderCondition{
+					query: "EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_pk = o.id AND oi.item_name ILIKE ?)",
+					args:  []interface{}{"%" + value + "%"},
+				})
+				f.descriptions = append(f.descriptions, fmt.Sprintf("Kalem içerir: %s", value))
+			}
+		default:
+			text, ok := orderTextFilters[key]
+			if !ok {
+				return f, fmt.Errorf("bilinmeyen filtre: %s", key)
+			}
+			switch op {
+			case "=":
+				f.conditions = append(f.conditions, orderCondition{query: "LOWER(" + text.Column + ") = LOWER(?)", args: []interface{}{value}})
+				f.descriptions = append(f.descriptions, fmt.Sprintf("%s: %s", text.Title, value))
+			case "!=":
+				f.conditions = append(f.conditions, orderCondition{query: "COALESCE(LOWER(" + text.Column + "), '') != LOWER(?)", args: []interface{}{value}})
+				f.descriptions = append(f.descriptions, fmt.Sprintf("%s hariç: %s", text.Title, value))
+			case "~":
+				f.conditions = append(f.conditions, orderCondition{query: text.Column + " ILIKE ?", args: []interface{}{"%" + value + "%"}})
+				f.descriptions = append(f.descriptions, fmt.Sprintf("%s içerir: %s", text.Title, value))
+			default:
+				return f, fmt.Errorf("%s için =, != veya ~ kullanılabilir", key)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// addAmount tutar filtresini ekler (>, >=, <, <=, =, != , ~ yaklaşık, = aralık)
+func (f *orderFilter) addAmount(op, value string) error {
+	// Aralık: tutar=100-200
+	if op == "=" && strings.Contains(value, "-") {
+		parts := strings.SplitN(value, "-", 2)
+		min, err1 := strconv.ParseFloat(strings.ReplaceAll(parts[0], ",", "."), 64)
+		max, err2 := strconv.ParseFloat(strings.ReplaceAll(parts[1], ",", "."), 64)
+		if err1 != nil || err2 != nil || min > max {
+			return fmt.Errorf("geçersiz tutar aralığı: %s", value)
+		}
+		f.conditions = append(f.conditions, orderCondition{query: "o.amount BETWEEN ? AND ?", args: []interface{}{min, max}})
+		f.descriptions = append(f.descriptions, fmt.Sprintf("Tutar: %.2f - %.2f", min, max))
+		return nil
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil {
+		return fmt.Errorf("geçersiz tutar: %s", value)
+	}
+
+	if op == "~" {
+		min, max := amount*(1-fuzzyAmountRatio), amount*(1+fuzzyAmountRatio)
+		f.conditions = append(f.conditions, orderCondition{query: "o.amount BETWEEN ? AND ?", args: []interface{}{min, max}})
+		f.descriptions = append(f.descriptions, fmt.Sprintf("Tutar ≈ %.2f (%.2f - %.2f)", amount, min, max))
+		return nil
+	}
+
+	f.conditions = append(f.conditions, orderCondition{query: "o.amount " + op + " ?", args: []interface{}{amount}})
+	f.descriptions = append(f.descriptions, fmt.Sprintf("Tutar %s %.2f", op, amount))
+	return nil
+}
+
+// addDate tarih filtresini ekler; saat verilmişse pencereyi bulanık olarak genişletir
+func (f *orderFilter) addDate(value, timeToken string) error {
+	loc := getTurkeyLocation()
+
+	var day time.Time
+	switch strings.ToLower(value) {
+	case "bugun", "bugün":
+		_, _, day = getDayRangeUTC(0)
+	case "dun", "dün":
+		_, _, day = getDayRangeUTC(-1)
+	default:
+		parsed, err := time.ParseInLocation("02.01.2006", value, loc)
+		if err != nil {
+			return fmt.Errorf("geçersiz tarih: %s (bugun, dun veya GG.AA.YYYY)", value)
+		}
+		day = parsed
+	}
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+	start, end := dayStart, dayStart.AddDate(0, 0, 1)
+	description := fmt.Sprintf("Tarih: %s", dayStart.Format("02.01.2006"))
+
+	if timeToken != "" {
+		m := filterTimeRegex.FindStringSubmatch(timeToken)
+		from, err := clockOffset(m[1], m[2])
+		if err != nil {
+			return err
+		}
+		if m[3] == "" {
+			// Tek saat: "14:30 civarı"
+			start = dayStart.Add(from - fuzzyTimeWindow)
+			end = dayStart.Add(from + fuzzyTimeWindow)
+			description += fmt.Sprintf(" %s civarı (±%d dk)", timeToken, int(fuzzyTimeWindow.Minutes()))
+		} else {
+			to, err := clockOffset(m[3], m[4])
+			if err != nil {
+				return err
+			}
+			if to <= from {
+				return fmt.Errorf("geçersiz saat aralığı: %s", timeToken)
+			}
+			start = dayStart.Add(from - fuzzyRangeSlack)
+			end = dayStart.Add(to + fuzzyRangeSlack)
+			description += fmt.Sprintf(" %s (±%d dk)", timeToken, int(fuzzyRangeSlack.Minutes()))
+		}
+	}
+
+	f.conditions = append(f.conditions, orderCondition{query: "o.event_time >= ? AND o.event_time < ?", args: []interface{}{start.UTC(), end.UTC()}})
+	f.descriptions = append(f.descriptions, description)
+	return nil
+}
+
+// clockOffset "14", "30" değerlerini gün başından itibaren süreye çevirir
+func clockOffset(hour, minute string) (time.Duration, error) {
+	h, _ := strconv.Atoi(hour)
+	m, _ := strconv.Atoi(minute)
+	if h > 23 || m > 59 {
+		return 0, fmt.Errorf("geçersiz saat: %s:%s", hour, minute)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// apply filtre koşullarını sorguya ekler (orders tablosu "o" alias'ı ile seçilmiş olmalı)
+func (f orderFilter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	for _, c := range f.conditions {
+		q = q.Where(c.query, c.args...)
+	}
+	return q
+}
+
+// writeDescription filtre özetini mesaja yazar
+func (f orderFilter) writeDescription(sb *strings.Builder) {
+	for _, d := range f.descriptions {
+		sb.WriteString(htmlf("🔎 %s\n", d))
+	}
+	sb.WriteString("\n")
+}
+
+// orderFilterHelp filtre dilinin kullanım açıklaması (/ara ve /sorgu ortak)
+const orderFilterHelp = `🔎 <b>Filtre Kullanımı</b>
+
+<code>tutar&gt;500</code> — 500 üzeri (&gt;, &gt;=, &lt;, &lt;=, =, !=)
+<code>tutar~250</code> — 250 civarı (±%10)
+<code>tutar=100-200</code> — Tutar aralığı
+<code>tarih=dun 14:00-15:00</code> — Saat aralığı (±15 dk)
+<code>tarih=12.03.2025 14:30</code> — Saat civarı (±30 dk)
+<code>kaynak=meta</code> — Kaynak (ortam, kampanya, icerik, kanal, id de kullanılabilir)
+<code>kampanya~ramazan</code> — İçeren (~) / hariç (!=)
+<code>kalem="Su Kuyusu"</code> — Kalemi tam eşleşen bağışlar (kalem~su: içeren)`
+
+// Komut bazlı filtre kullanım metinleri
+const (
+	araUsage   = orderFilterHelp + "\n\nÖrnek: <code>/ara tutar~500 tarih=dun 14:30 kaynak=meta</code>"
+	sorguUsage = orderFilterHelp + "\n\nÖrnek: <code>/sorgu kaynak=meta tarih=dun</code>\nFiltresiz <code>/sorgu</code> tüm bağışları özetler."
+)
+
+// handleAraCommand /ara komutunu işler - Filtreye uyan bağışları listeler
+func handleAraCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
+
+	filter, err := parseOrderFilter(args)
+	if err != nil || len(filter.conditions) == 0 {
+		text := araUsage
+		if err != nil {
+			text = htmlf("⚠️ %s\n\n%s", err, htmlText(araUsage))
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	var orders []Order
+	count, err := filter.apply(scope.orderModels(&orders)).
+		OrderExpr("o.event_time DESC").
+		Limit(10).
+		ScanAndCount(ctx)
+	if err != nil {
+		log.Printf("Arama sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔍 <b>Bağış Arama</b>\n\n")
+	filter.writeDescription(&sb)
+
+	if count == 0 {
+		sb.WriteString("ℹ️ Filtreye uyan bağış bulunamadı.")
+	} else {
+		sb.WriteString(htmlf("📋 <b>%d</b> bağış bulundu", count))
+		if count > len(orders) {
+			sb.WriteString(htmlf(" (en yeni %d gösteriliyor)", len(orders)))
+		}
+		sb.WriteString("\n\n")
+
+		loc := getTurkeyLocation()
+		for i, o := range orders {
+			sb.WriteString(htmlf("<b>%d.</b> 💰 %s — <code>%s</code>\n", i+1, formatMoney(o.Amount, o.Currency), o.OrderID))
+			sb.WriteString(htmlf("   📅 %s\n", o.EventTime.In(loc).Format("02.01.2006 15:04:05")))
+			if o.UTMSource != "" || o.UTMMedium != "" {
+				sb.WriteString(htmlf("   📊 %s / %s\n", o.UTMSource, o.UTMMedium))
+			}
+			if o.UTMCampaign != "" {
+				sb.WriteString(htmlf("   🎯 %s\n", o.UTMCampaign))
+			}
+			if len(o.Items) > 0 {
+				names := make([]string, 0, len(o.Items))
+				for _, item := range o.Items {
+					names = append(names, item.ItemName)
+				}
+				sb.WriteString(htmlf("   📦 %s\n", strings.Join(names, ", ")))
+			}
+			if o.IsTest {
+				sb.WriteString("   🧪 Test verisi\n")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// handleSorguCommand /sorgu komutunu işler - Filtreye uyan bağışların özetini verir
+func handleSorguCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+	scope := getDataScope(ctx, chatID, userID)
+
+	filter, err := parseOrderFilter(args)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, htmlf("⚠️ %s\n\n%s", err, htmlText(sorguUsage)))
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	// Farklı para birimleri toplanmaz, özet para birimi bazında verilir
+	var summaries []struct {
+		Currency string  `bun:"currency"`
+		Count    int     `bun:"count"`
+		Total    float64 `bun:"total"`
+		Avg      float64 `bun:"avg"`
+		Min      float64 `bun:"min"`
+		Max      float64 `bun:"max"`
+	}
+	err = filter.apply(db.NewSelect().TableExpr("(?) AS o", scope.orders())).
+		ColumnExpr("o.currency").
+		ColumnExpr("COUNT(*) as count").
+		ColumnExpr("SUM(o.amount) as total").
+		ColumnExpr("AVG(o.amount) as avg").
+		ColumnExpr("MIN(o.amount) as min").
+		ColumnExpr("MAX(o.amount) as max").
+		GroupExpr("o.currency").
+		OrderExpr("count DESC").
+		Scan(ctx, &summaries)
+	if err != nil {
+		log.Printf("Sorgu özet hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	var sources []struct {
+		UTMSource string  `bun:"utm_source"`
+		Currency  string  `bun:"currency"`
+		Total     float64 `bun:"total"`
+		Count     int     `bun:"count"`
+	}
+	err = dimensionTotalsQuery(
+		filter.apply(db.NewSelect().TableExpr("(?) AS o", scope.orders())).
+			ColumnExpr("o.currency").
+			ColumnExpr("SUM(o.amount) as total").
+			ColumnExpr("COUNT(*) as count").
+			GroupExpr("o.currency"),
+		"utm_source", "currency", "total", "count").
+		OrderExpr("total DESC").
+		Limit(5).
+		Scan(ctx, &sources)
+	if err != nil {
+		log.Printf("Sorgu kaynak hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📐 <b>Sorgu Özeti</b>\n\n")
+	if len(filter.conditions) == 0 {
+		sb.WriteString("🔎 Filtre yok (tüm bağışlar)\n\n")
+	} else {
+		filter.writeDescription(&sb)
+	}
+
+	var count int
+	for _, summary := range summaries {
+		count += summary.Count
+	}
+
+	if count == 0 {
+		sb.WriteString("ℹ️ Filtreye uyan bağış bulunamadı.")
+	} else {
+		sb.WriteString(htmlf("📋 Bağış Sayısı: <b>%d</b>\n", count))
+		for _, summary := range summaries {
+			if len(summaries) > 1 {
+				sb.WriteString(htmlf("\n💱 <b>%s</b> (%d bağış)\n", summary.Currency, summary.Count))
+			}
+			sb.WriteString(htmlf("💰 Toplam: <b>%s</b>\n", formatMoney(summary.Total, summary.Currency)))
+			sb.WriteString(htmlf("📊 Ortalama: %s\n", formatMoney(summary.Avg, summary.Currency)))
+			sb.WriteString(htmlf("⬇️ En Düşük: %s\n", formatMoney(summary.Min, summary.Currency)))
+			sb.WriteString(htmlf("⬆️ En Yüksek: %s\n", formatMoney(summary.Max, summary.Currency)))
+		}
+
+		sb.WriteString("\n📡 <b>Kaynaklar (Top 5)</b>\n")
+		for i, s := range sources {
+			sb.WriteString(htmlf("%s %s: %s (%d)\n", getEmojiByRank(i), s.UTMSource, formatMoney(s.Total, s.Currency), s.Count))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// GoogleAdsCampaign Google Ads hesabından senkronize edilen kampanya listesini tutar
+type GoogleAdsCampaign struct {
+	bun.BaseModel `bun:"table:google_ads_campaigns,alias:gac"`
+
+	CampaignID string    `bun:"campaign_id,pk"`
+	Name       string    `bun:"name,notnull"`
+	Status     string    `bun:"status,notnull"` // ENABLED, PAUSED, REMOVED
+	SyncedAt   time.Time `bun:"synced_at,notnull"`
+}
+
+// Google Ads'te yayında olan kampanyanın durumu
+const googleAdsStatusEnabled = "ENABLED"
+
+// GoogleAdsSyncRequest POST /google-ads/campaigns gövdesi (liste her seferinde tamamen gönderilir)
+type GoogleAdsSyncRequest struct {
+	Campaigns []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"campaigns"`
+}
+
+// handleGoogleAdsCampaignSync POST /google-ads/campaigns - Kampanya listesini gelen listeyle değiştirir
+func handleGoogleAdsCampaignSync(c *fiber.Ctx) error {
+	var req GoogleAdsSyncRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Printf("Google Ads senkron JSON parse hatası: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Geçersiz JSON formatı",
+		})
+	}
+
+	syncedAt := time.Now()
+	campaigns := make([]GoogleAdsCampaign, 0, len(req.Campaigns))
+	for _, rc := range req.Campaigns {
+		id := strings.TrimSpace(rc.ID)
+		if id == "" {
+			continue
+		}
+		campaigns = append(campaigns, GoogleAdsCampaign{
+			CampaignID: id,
+			Name:       rc.Name,
+			Status:     strings.ToUpper(strings.TrimSpace(rc.Status)),
+			SyncedAt:   syncedAt,
+		})
+	}
+	if len(campaigns) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Kampanya listesi boş",
+		})
+	}
+
+	// Listede olmayan eski kampanyalar silinir, böylece tablo hesabın güncel halini yansıtır
+	err := db.RunInTx(c.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().
+			Model(&campaigns).
+			On("CONFLICT (campaign_id) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Set("status = EXCLUDED.status").
+			Set("synced_at = EXCLUDED.synced_at").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*GoogleAdsCampaign)(nil)).Where("synced_at < ?", syncedAt).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("Google Ads senkron kayıt hatası: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Veritabanı hatası",
+		})
+	}
+
+	log.Printf("Google Ads kampanya listesi senkronize edildi: %d kampanya", len(campaigns))
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"campaigns": len(campaigns),
+	})
+}
+
+// handleGadsKontrolCommand /gads_kontrol komutunu işler - gad_campaignid değerlerini Ads kampanya listesiyle karşılaştırır
+func handleGadsKontrolCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+
+	// Ads hesabı tüm ofislerin kampanyalarını içerir, kapsamlı sohbetlerde eşleşmeyenler yanlış alarm olur
+	if getDataScope(ctx, chatID, userID).isScoped() {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Bu rapor hesap geneli olduğundan veri kapsamı tanımlı sohbetlerde kullanılamaz.")
+		bot.Send(msg)
+		return
+	}
+
+	stopProgress := startProgress(bot, chatID, tgbotapi.ChatTyping, "Kontrol raporu")
+	defer stopProgress()
+
+	startDate, endDate, hasDateFilter := parseDateRange(args)
+	if !hasDateFilter {
+		startDate, _, _ = getDayRangeUTC(-29)
+		_, endDate, _ = getDayRangeUTC(0)
+	}
+
+	var lastSync struct {
+		Count    int       `bun:"count"`
+		SyncedAt time.Time `bun:"synced_at"`
+	}
+	err := db.NewSelect().
+		Model((*GoogleAdsCampaign)(nil)).
+		ColumnExpr("COUNT(*) as count").
+		ColumnExpr("COALESCE(MAX(synced_at), '0001-01-01') as synced_at").
+		Scan(ctx, &lastSync)
+	if err != nil {
+		log.Printf("Google Ads kontrol sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+	if lastSync.Count == 0 {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Google Ads kampanya listesi henüz senkronize edilmemiş.\n\nListe POST /google-ads/campaigns endpoint'ine gönderilmelidir.")
+		bot.Send(msg)
+		return
+	}
+
+	// gad_campaignid ve para birimi bazında gelir ve listedeki karşılığı
+	var trackedRows []struct {
+		GadCampaignID string         `bun:"gad_campaignid"`
+		Currency      string         `bun:"currency"`
+		Total         float64        `bun:"total"`
+		Count         int            `bun:"count"`
+		Name          sql.NullString `bun:"name"`
+		Status        sql.NullString `bun:"status"`
+	}
+	err = db.NewSelect().
+		TableExpr("orders AS o").
+		Join("LEFT JOIN google_ads_campaigns AS gac ON gac.campaign_id = o.gad_campaignid").
+		ColumnExpr("o.gad_campaignid").
+		ColumnExpr("o.currency").
+		ColumnExpr("SUM(o.amount) as total").
+		ColumnExpr("COUNT(*) as count").
+		ColumnExpr("gac.name").
+		ColumnExpr("gac.status").
+		Where("o.gad_campaignid IS NOT NULL AND o.gad_campaignid != ''").
+		Where("o.event_time >= ?", startDate).
+		Where("o.event_time <= ?", endDate).
+		GroupExpr("o.gad_campaignid, o.currency, gac.name, gac.status").
+		OrderExpr("SUM(COUNT(*)) OVER (PARTITION BY o.gad_campaignid) DESC, o.gad_campaignid, count DESC").
+		Scan(ctx, &trackedRows)
+	if err != nil {
+		log.Printf("Google Ads kontrol sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	// Yayında olup dönemde hiç gelir getirmeyen kampanyalar (tracking template eksik olabilir)
+	var silent []GoogleAdsCampaign
+	err = db.NewSelect().
+		Model(&silent).
+		Where("gac.status = ?", googleAdsStatusEnabled).
+		Where("NOT EXISTS (SELECT 1 FROM orders o WHERE o.gad_campaignid = gac.campaign_id AND o.event_time >= ? AND o.event_time <= ?)", startDate, endDate).
+		OrderExpr("gac.name").
+		Scan(ctx)
+	if err != nil {
+		log.Printf("Google Ads kontrol sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	// Google trafiği olup gad_campaignid taşımayan bağışlar (para birimi bazında)
+	var campaignless moneyTotals
+	err = db.NewSelect().
+		TableExpr("orders").
+		ColumnExpr("currency").
+		ColumnExpr("SUM(amount) as total").
+		ColumnExpr("COUNT(*) as count").
+		Where("(gad_source IS NOT NULL AND gad_source != '') OR traffic_channel = 'google'").
+		Where("gad_campaignid IS NULL OR gad_campaignid = ''").
+		Where("event_time >= ?", startDate).
+		Where("event_time <= ?", endDate).
+		GroupExpr("currency").
+		OrderExpr("count DESC, currency").
+		Scan(ctx, &campaignless)
+	if err != nil {
+		log.Printf("Google Ads kontrol sorgu hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+
+	// Para birimi satırlarını kampanya bazında birleştir (sıra korunur)
+	type trackedCampaign struct {
+		GadCampaignID string
+		Name          sql.NullString
+		Status        sql.NullString
+		Totals        moneyTotals
+	}
+	var tracked []trackedCampaign
+	for _, row := range trackedRows {
+		if len(tracked) == 0 || tracked[len(tracked)-1].GadCampaignID != row.GadCampaignID {
+			tracked = append(tracked, trackedCampaign{GadCampaignID: row.GadCampaignID, Name: row.Name, Status: row.Status})
+		}
+		tracked[len(tracked)-1].Totals.add(row.Currency, row.Total, row.Count)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔍 <b>Google Ads Kampanya Kontrolü</b>\n")
+	sb.WriteString(htmlf("📅 %s - %s\n", startDate.In(getTurkeyLocation()).Format("02.01.2006"), endDate.In(getTurkeyLocation()).Format("02.01.2006")))
+	sb.WriteString(htmlf("🔄 Son senkron: %s (%d kampanya)\n\n", lastSync.SyncedAt.In(getTurkeyLocation()).Format("02.01.2006 15:04"), lastSync.Count))
+
+	issues := 0
+
+	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+	sb.WriteString("❓ <b>Gelir var, aktif kampanya yok</b>\n")
+	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+	for _, t := range tracked {
+		switch {
+		case !t.Name.Valid:
+			sb.WriteString(htmlf("• <code>%s</code> — listede yok\n", t.GadCampaignID))
+		case t.Status.String != googleAdsStatusEnabled:
+			sb.WriteString(htmlf("• <code>%s</code> %s — %s\n", t.GadCampaignID, t.Name.String, t.Status.String))
+		default:
+			continue
+		}
+		sb.WriteString(htmlf("   💰 %s (%d bağış)\n", t.Totals.String(), t.Totals.count()))
+		issues++
+	}
+	if issues == 0 {
+		sb.WriteString("✅ Tüm gad_campaignid değerleri aktif kampanyalarla eşleşiyor.\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+	sb.WriteString("🔇 <b>Aktif kampanya, gelir yok</b>\n")
+	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+	if len(silent) == 0 {
+		sb.WriteString("✅ Tüm aktif kampanyalar dönemde gelir getirmiş.\n")
+	}
+	for i, g := range silent {
+		if i == 20 {
+			sb.WriteString(htmlf("... ve %d kampanya daha\n", len(silent)-i))
+			break
+		}
+		sb.WriteString(htmlf("• <code>%s</code> %s\n", g.CampaignID, g.Name))
+	}
+	sb.WriteString("\n")
+
+	if campaignless.count() > 0 {
+		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+		sb.WriteString("🚫 <b>gad_campaignid olmayan Google trafiği</b>\n")
+		sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━\n")
+		sb.WriteString(htmlf("💰 %s (%d bağış)\n\n", campaignless.String(), campaignless.count()))
+	}
+
+	if issues > 0 || len(silent) > 0 || campaignless.count() > 0 {
+		sb.WriteString("<i>Ads hesabındaki tracking template / final URL suffix ayarlarını kontrol edin.</i>")
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// Telegram chat action'ı ~5 saniye gösterir, bu yüzden iş sürdükçe tekrar gönderilir
+const chatActionRefreshInterval = 4 * time.Second
+
+// startProgress uzun süren komutlar için hemen "hazırlanıyor" mesajı ve chat action gönderir.
+// Dönen fonksiyon sonuç gönderildikten sonra çağrılmalıdır (defer); bekleme mesajını siler.
+func startProgress(bot *tgbotapi.BotAPI, chatID int64, action string, title string) func() {
+	ack, err := bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ %s hazırlanıyor...", title)))
+	if err != nil {
+		log.Printf("Bekleme mesajı gönderilemedi (chat_id=%d): %v", chatID, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(chatActionRefreshInterval)
+		defer ticker.Stop()
+		for {
+			bot.Request(tgbotapi.NewChatAction(chatID, action))
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			if err == nil {
+				bot.Request(tgbotapi.NewDeleteMessage(chatID, ack.MessageID))
+			}
+		})
+	}
+}
+
+// loadTestResult tek bir yük testi isteğinin sonucu
+type loadTestResult struct {
+	Latency time.Duration
+	Status  int // 0 = bağlantı hatası
+}
+
+// loadTestTokenHeader yük testi isteklerinin LOAD_TEST_TOKEN'ı taşıdığı header
+const loadTestTokenHeader = "X-Load-Test-Token"
+
+// maxLoadTestRPS ticker aralığının sıfıra düşmemesi için üst sınır
+const maxLoadTestRPS = 1000
+
+// isLoadTestRequest isteğin geçerli LOAD_TEST_TOKEN taşıyıp taşımadığını döner (token tanımlı değilse false)
+func isLoadTestRequest(c *fiber.Ctx) bool {
+	expected := os.Getenv("LOAD_TEST_TOKEN")
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Get(loadTestTokenHeader)), []byte(expected)) == 1
+}
+
+// runLoadTest hedef API'ye sabit hızda sentetik (is_test) sipariş gönderir ve gecikme/hata oranını raporlar
+func runLoadTest(target string, rps int, duration time.Duration) error {
+	if rps <= 0 || duration <= 0 {
+		return fmt.Errorf("rps ve duration pozitif olmalı")
+	}
+	if rps > maxLoadTestRPS {
+		log.Printf("UYARI: rps %d çok yüksek, %d kullanılacak", rps, maxLoadTestRPS)
+		rps = maxLoadTestRPS
+	}
+	token := os.Getenv("LOAD_TEST_TOKEN")
+	if token == "" {
+		return fmt.Errorf("LOAD_TEST_TOKEN ayarlanmamış (hedef API is_test siparişlerini token olmadan reddeder)")
+	}
+	endpoint := strings.TrimRight(target, "/") + "/throw-data"
+	log.Printf("Yük testi başlıyor: %s, %d istek/sn, %s", endpoint, rps, duration)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	var results []loadTestResult
+	var resultsMutex sync.Mutex
+	var wg sync.WaitGroup
+
+	// Açık döngü: yanıt beklenmeden sabit aralıkla istek atılır (TV yayını gibi ani yükü taklit eder)
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+	deadline := time.After(duration)
+	started := time.Now()
+
+	for seq := 0; ; seq++ {
+		select {
+		case <-deadline:
+			wg.Wait()
+			return reportLoadTest(results, time.Since(started))
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		order := generateSampleOrder(rng, now, now, seq)
+		body, err := json.Marshal(ThrowDataRequest{
+			OrderID:        order.OrderID,
+			Amount:         order.Amount,
+			Currency:       order.Currency,
+			Items:          order.Items,
+			UTMSource:      order.UTMSource,
+			UTMMedium:      order.UTMMedium,
+			UTMCampaign:    order.UTMCampaign,
+			UTMContent:     order.UTMContent,
+			GadSource:      order.GadSource,
+			GadCampaignID:  order.GadCampaignID,
+			TrafficChannel: order.TrafficChannel,
+			EventTime:      order.EventTime,
+			IsTest:         true,
+		})
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := loadTestResult{}
+			requestStart := time.Now()
+			var resp *http.Response
+			req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+			if err == nil {
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				req.Header.Set(loadTestTokenHeader, token)
+				resp, err = client.Do(req)
+			}
+			result.Latency = time.Since(requestStart)
+			if err == nil {
+				result.Status = resp.StatusCode
+				resp.Body.Close()
+			}
+			resultsMutex.Lock()
+			results = append(results, result)
+			resultsMutex.Unlock()
+		}()
+	}
+}
+
+// reportLoadTest yük testi sonuçlarını özetler (p50/p95/p99 gecikme, hata oranı)
+func reportLoadTest(results []loadTestResult, elapsed time.Duration) error {
+	if len(results) == 0 {
+		return fmt.Errorf("hiç istek gönderilmedi")
+	}
+
+	latencies := make([]time.Duration, 0, len(results))
+	statusCounts := make(map[int]int)
+	failures := 0
+	for _, r := range results {
+		latencies = append(latencies, r.Latency)
+		statusCounts[r.Status]++
+		if r.Status != fiber.StatusOK {
+			failures++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(float64(len(latencies)-1)*p)]
+	}
+
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("📈 Yük Testi Sonucu")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("İstek: %d (%.1f istek/sn)\n", len(results), float64(len(results))/elapsed.Seconds())
+	fmt.Printf("Hata: %d (%%%.2f)\n", failures, float64(failures)*100/float64(len(results)))
+	fmt.Printf("p50: %s | p95: %s | p99: %s | max: %s\n",
+		percentile(0.50).Round(time.Millisecond), percentile(0.95).Round(time.Millisecond),
+		percentile(0.99).Round(time.Millisecond), latencies[len(latencies)-1].Round(time.Millisecond))
+
+	statuses := make([]int, 0, len(statusCounts))
+	for status := range statusCounts {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		label := strconv.Itoa(status)
+		if status == 0 {
+			label = "bağlantı hatası"
+		}
+		fmt.Printf("  %s: %d\n", label, statusCounts[status])
+	}
+	return nil
+}
+
+// runMode sürecin hangi bileşenleri çalıştıracağını belirtir
+// API yatayda çoğaltılabilir; bot (Telegram poller) ve worker (zamanlanmış işler) tek örnek çalışmalıdır
+type runMode struct {
+	Bot    bool // Telegram güncellemelerini alır ve komutları işler
+	API    bool // /throw-data ve rapor endpoint'lerini sunar
+	Worker bool // Heartbeat gibi tekil zamanlanmış işleri çalıştırır
+}
+
+// ingestInProcess aynı süreçte /throw-data çalışıyorsa true (read-model ingest ile canlı güncellenir)
+var ingestInProcess bool
+
+// parseRunMode --mode değerini çözer
+func parseRunMode(name string) (runMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "all", "":
+		return runMode{Bot: true, API: true, Worker: true}, nil
+	case "bot":
+		return runMode{Bot: true}, nil
+	case "api":
+		return runMode{API: true}, nil
+	case "worker":
+		return runMode{Worker: true}, nil
+	default:
+		return runMode{}, fmt.Errorf("geçersiz çalışma modu: %s (bot, api, worker, all)", name)
+	}
+}
+
+// all tüm bileşenlerin tek süreçte çalışıp çalışmadığını döner (varsayılan mod)
+func (m runMode) all() bool {
+	return m.Bot && m.API && m.Worker
+}
+
+// Liderlik kilitleri için advisory lock anahtar öneki (aynı veritabanındaki başka uygulamalarla çakışmasın)
+const leaderLockPrefix = "utm_builder:"
+
+const (
+	leaderRetryInterval    = 10 * time.Second // Kilit başka örnekteyken tekrar deneme aralığı
+	leaderMaxRetryInterval = 2 * time.Minute  // Veritabanı hatalarında artan bekleme süresinin üst sınırı
+	leaderCheckInterval    = 15 * time.Second // Kilidi tutan bağlantının kontrol aralığı
+)
+
+// leaderElection liderlik seçiminin açık olup olmadığı (all modunda ve veritabanı yokken kapalı, main'de ayarlanır)
+var leaderElection bool
+
+// acquireLeadership Postgres advisory lock alınana kadar bekler ve liderlik sürdükçe açık kalan bir context döner.
+// Kilit oturuma bağlıdır; bağlantı havuzdan ayrılıp açık tutulur, koparsa context iptal edilir ve
+// çağıran lider işleri durdurup kilidi yeniden bekler. Seçim kapalıysa hemen hiç iptal edilmeyen context döner.
+func acquireLeadership(name string) context.Context {
+	ctx := context.Background()
+	if !leaderElection {
+		return ctx
+	}
+
+	waiting := false
+	backoff := leaderRetryInterval
+
+	for {
+		conn, err := db.Conn(ctx)
+		if err == nil {
+			var acquired bool
+			err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext(?))", leaderLockPrefix+name).Scan(&acquired)
+			if err == nil && acquired {
+				log.Printf("Liderlik alındı: %s", name)
+				leaderCtx, lost := context.WithCancel(ctx)
+				go watchLeadership(conn, name, lost)
+				return leaderCtx
+			}
+			conn.Close()
+		}
+
+		if err != nil {
+			log.Printf("Liderlik kilidi alınamadı (%s), %s sonra tekrar denenecek: %v", name, backoff, err)
+			time.Sleep(backoff)
+			backoff = min(backoff*2, leaderMaxRetryInterval)
+			continue
+		}
+
+		backoff = leaderRetryInterval
+		if !waiting {
+			log.Printf("%s başka bir örnekte çalışıyor, yedekte bekleniyor", name)
+			waiting = true
+		}
+		time.Sleep(leaderRetryInterval)
+	}
+}
+
+// watchLeadership kilidi tutan bağlantıyı kontrol eder, koparsa kilit düşmüş sayılır ve liderlik context'i iptal edilir
+func watchLeadership(conn bun.Conn, name string, lost context.CancelFunc) {
+	defer lost()
+	defer conn.Close()
+
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := conn.ExecContext(ctx, "SELECT 1")
+		cancel()
+		if err != nil {
+			log.Printf("Liderlik kaybedildi (%s), lider işler durduruluyor ve kilit yeniden beklenecek: %v", name, err)
+			return
+		}
+	}
+}
+
+// DataScope bir sohbetin (grup, kanal veya kullanıcının özel sohbeti) görebileceği veri alt kümesini tanımlar.
+// Kapsam tanımlı sohbetlerde tüm rapor sorguları ve bildirimler bu filtreyle sınırlanır (örn. ülke ofisleri).
+type DataScope struct {
+	bun.BaseModel `bun:"table:data_scopes,alias:ds"`
+
+	ChatID         int64     `bun:"chat_id,pk"`      // Grup/kanal ID'si veya kullanıcının özel sohbet (= user) ID'si
+	Label          string    `bun:"label"`           // Örn. "Almanya"
+	CampaignPrefix string    `bun:"campaign_prefix"` // Örn. "de_" (büyük/küçük harf duyarsız)
+	UTMSources     []string  `bun:"utm_sources,array"`
+	UpdatedBy      int64     `bun:"updated_by"`
+	UpdatedAt      time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	denyAll bool `bun:"-"` // Kapsamlar okunamadıysa hiçbir veri gösterilmez
+}
+
+// Kapsamlar her sorguda okunmaz, kısa süreli önbellekte tutulur (API örnekleri de değişiklikleri görür)
+const dataScopeCacheTTL = time.Minute
+
+var dataScopes map[int64]DataScope
+var dataScopesLoadedAt time.Time
+var dataScopesMutex sync.Mutex
+
+// getDataScope komutu çalıştıran kullanıcının bu sohbetteki veri kapsamını döner.
+// Sohbetin kapsamı ile kullanıcının özel sohbet kapsamı kesiştirilir; kısıtlı bir kullanıcı
+// kapsamsız bir gruba yazarak tüm verileri göremez.
+func getDataScope(ctx context.Context, chatID int64, userID int64) DataScope {
+	scope := getChatScope(ctx, chatID)
+	if userID == 0 || userID == chatID {
+		return scope
+	}
+	return scope.intersect(getChatScope(ctx, userID))
+}
+
+// getChatScope sohbetin veri kapsamını döner (kapsam yoksa sıfır değer = tüm veriler)
+func getChatScope(ctx context.Context, chatID int64) DataScope {
+	dataScopesMutex.Lock()
+	defer dataScopesMutex.Unlock()
+
+	if dataScopes == nil || time.Since(dataScopesLoadedAt) > dataScopeCacheTTL {
+		var scopes []DataScope
+		if err := db.NewSelect().Model(&scopes).Scan(ctx); err != nil {
+			log.Printf("Veri kapsamı sorgu hatası: %v", err)
+			if dataScopes == nil {
+				// Hangi sohbetin kısıtlı olduğu bilinmiyor, veri sızdırmamak için hiçbir şey gösterilmez
+				return DataScope{ChatID: chatID, denyAll: true}
+			}
+		} else {
+			dataScopes = make(map[int64]DataScope, len(scopes))
+			for _, scope := range scopes {
+				dataScopes[scope.ChatID] = scope
+			}
+			dataScopesLoadedAt = time.Now()
+		}
+	}
+	return dataScopes[chatID]
+}
+
+// invalidateDataScopes kapsam önbelleğini temizler (değişiklikten sonra hemen geçerli olsun)
+func invalidateDataScopes() {
+	dataScopesMutex.Lock()
+	dataScopes = nil
+	dataScopesMutex.Unlock()
+}
+
+// isScoped sohbetin veri kapsamı ile sınırlı olup olmadığını döner
+func (s DataScope) isScoped() bool {
+	return s.denyAll || s.CampaignPrefix != "" || len(s.UTMSources) > 0
+}
+
+// intersect iki kapsamın ikisine birden giren verileri gösteren kapsamı döner
+func (s DataScope) intersect(other DataScope) DataScope {
+	if !other.isScoped() {
+		return s
+	}
+	if !s.isScoped() {
+		return other
+	}
+	result := s
+	if s.denyAll || other.denyAll {
+		result.denyAll = true
+		return result
+	}
+
+	// Önekler: biri diğerini kapsıyorsa uzun olan geçerlidir, aksi halde kesişim boştur
+	prefix, otherPrefix := strings.ToLower(s.CampaignPrefix), strings.ToLower(other.CampaignPrefix)
+	switch {
+	case otherPrefix == "" || strings.HasPrefix(prefix, otherPrefix):
+	case prefix == "" || strings.HasPrefix(otherPrefix, prefix):
+		result.CampaignPrefix = other.CampaignPrefix
+	default:
+		result.denyAll = true
+		return result
+	}
+
+	// Kaynaklar: iki liste de tanımlıysa ortak olanlar kalır
+	if len(other.UTMSources) > 0 {
+		if len(s.UTMSources) == 0 {
+			result.UTMSources = other.UTMSources
+		} else {
+			var common []string
+			for _, source := range s.UTMSources {
+				for _, otherSource := range other.UTMSources {
+					if strings.EqualFold(source, otherSource) {
+						common = append(common, source)
+						break
+					}
+				}
+			}
+			if len(common) == 0 {
+				result.denyAll = true
+				return result
+			}
+			result.UTMSources = common
+		}
+	}
+	return result
+}
+
+// likeEscaper LIKE özel karakterlerini kaçırır ("de_" önekindeki _ joker karakter sayılmasın)
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// orders kapsama göre filtrelenmiş orders alt sorgusunu döner; rapor sorguları tablo yerine bunu kullanır
+// Ham UTM değerleri boyut tablolarından çözülür (orders_raw görünümü)
+func (s DataScope) orders() *bun.SelectQuery {
+	query := db.NewSelect().TableExpr("orders_raw")
+	if s.denyAll {
+		return query.Where("FALSE")
+	}
+	if s.CampaignPrefix != "" {
+		query = query.Where("utm_campaign ILIKE ?", likeEscaper.Replace(s.CampaignPrefix)+"%")
+	}
+	if len(s.UTMSources) > 0 {
+		sources := make([]string, len(s.UTMSources))
+		for i, source := range s.UTMSources {
+			sources[i] = strings.ToLower(source)
+		}
+		query = query.Where("LOWER(utm_source) IN (?)", bun.In(sources))
+	}
+	return query
+}
+
+// orderModels kapsamdaki siparişleri Order modeline okuyan sorguyu döner
+// UTM adları görünümden o.* ile alınır (ham sütunlar kaldırılmış olabilir)
+func (s DataScope) orderModels(orders *[]Order) *bun.SelectQuery {
+	return db.NewSelect().Model(orders).ModelTableExpr("(?) AS o", s.orders()).ColumnExpr("o.*")
+}
+
+// matches siparişin kapsama girip girmediğini döner (bildirim filtresi, orders() ile aynı kural)
+func (s DataScope) matches(order *Order) bool {
+	if s.denyAll {
+		return false
+	}
+	if s.CampaignPrefix != "" && !strings.HasPrefix(strings.ToLower(order.UTMCampaign), strings.ToLower(s.CampaignPrefix)) {
+		return false
+	}
+	if len(s.UTMSources) > 0 {
+		for _, source := range s.UTMSources {
+			if strings.EqualFold(source, order.UTMSource) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// describe kapsamı kullanıcıya gösterilecek şekilde özetler
+func (s DataScope) describe() htmlText {
+	var parts []string
+	if s.Label != "" {
+		parts = append(parts, htmlf("<b>%s</b>", s.Label))
+	}
+	if s.CampaignPrefix != "" {
+		parts = append(parts, htmlf("kampanya: <code>%s*</code>", s.CampaignPrefix))
+	}
+	if len(s.UTMSources) > 0 {
+		parts = append(parts, htmlf("kaynak: <code>%s</code>", strings.Join(s.UTMSources, ",")))
+	}
+	return htmlText(strings.Join(parts, " | "))
+}
+
+// handleKapsamCommand /kapsam komutunu işler - Sohbet bazlı veri kapsamlarını listeler/tanımlar/siler
+func handleKapsamCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	ctx := context.Background()
+	fields := strings.Fields(args)
+
+	// Listeleme: yöneticiler tüm kapsamları, diğerleri bu sohbetin kapsamını görür
+	if len(fields) == 0 {
+		var sb strings.Builder
+		sb.WriteString("🔒 <b>Veri Kapsamları</b>\n\n")
+
+		if !isAdmin(userID) {
+			if scope := getChatScope(ctx, chatID); scope.isScoped() {
+				sb.WriteString(htmlf("Bu sohbet sadece şu verileri görür: %s", scope.describe()))
+			} else {
+				sb.WriteString("Bu sohbet için veri kapsamı tanımlı değil, tüm veriler görünür.")
+			}
+			msg := tgbotapi.NewMessage(chatID, sb.String())
+			msg.ParseMode = "HTML"
+			bot.Send(msg)
+			return
+		}
+
+		var scopes []DataScope
+		if err := db.NewSelect().Model(&scopes).OrderExpr("chat_id").Scan(ctx); err != nil {
+			log.Printf("Veri kapsamı listeleme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		if len(scopes) == 0 {
+			sb.WriteString("ℹ️ Henüz veri kapsamı tanımlanmamış, tüm sohbetler tüm verileri görür.\n\n")
+		}
+		for _, scope := range scopes {
+			sb.WriteString(htmlf("<code>%d</code> — %s\n", scope.ChatID, scope.describe()))
+		}
+		sb.WriteString("\n<i>Tanımlamak için: /kapsam [chat_id] kampanya=de_ kaynak=meta,google etiket=Almanya</i>")
+
+		msg := tgbotapi.NewMessage(chatID, sb.String())
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	if !isAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Veri kapsamlarını sadece yöneticiler değiştirebilir.")
+		bot.Send(msg)
+		return
+	}
+
+	// Chat ID verilmezse komutun yazıldığı sohbet için ayarlanır
+	targetChatID := chatID
+	if id, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+		targetChatID = id
+		fields = fields[1:]
+	}
+
+	if len(fields) == 1 && fields[0] == "sil" {
+		_, err := db.NewDelete().Model((*DataScope)(nil)).Where("chat_id = ?", targetChatID).Exec(ctx)
+		if err != nil {
+			log.Printf("Veri kapsamı silme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		invalidateDataScopes()
+		log.Printf("Veri kapsamı silindi: chat=%d, user=%d", targetChatID, userID)
+		msg := tgbotapi.NewMessage(chatID, htmlf("✅ <code>%d</code> için veri kapsamı kaldırıldı.", targetChatID))
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	scope := &DataScope{ChatID: targetChatID, UpdatedBy: userID, UpdatedAt: time.Now()}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			msg := tgbotapi.NewMessage(chatID, "⚠️ Kullanım: /kapsam [chat_id] kampanya=de_ kaynak=meta,google etiket=Almanya")
+			bot.Send(msg)
+			return
+		}
+		switch strings.ToLower(key) {
+		case "kampanya":
+			scope.CampaignPrefix = value
+		case "kaynak":
+			scope.UTMSources = strings.Split(value, ",")
+		case "etiket":
+			scope.Label = value
+		default:
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Bilinmeyen alan: %s (kampanya, kaynak, etiket)", key))
+			bot.Send(msg)
+			return
+		}
+	}
+	if !scope.isScoped() {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ En az bir filtre gerekli: kampanya=<önek> veya kaynak=<liste>")
+		bot.Send(msg)
+		return
+	}
+
+	_, err := db.NewInsert().
+		Model(scope).
+		On("CONFLICT (chat_id) DO UPDATE").
+		Set("label = EXCLUDED.label").
+		Set("campaign_prefix = EXCLUDED.campaign_prefix").
+		Set("utm_sources = EXCLUDED.utm_sources").
+		Set("updated_by = EXCLUDED.updated_by").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Veri kapsamı kayıt hatası: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+		bot.Send(msg)
+		return
+	}
+	invalidateDataScopes()
+
+	log.Printf("Veri kapsamı tanımlandı: chat=%d, user=%d, kampanya=%s, kaynak=%v", targetChatID, userID, scope.CampaignPrefix, scope.UTMSources)
+	msg := tgbotapi.NewMessage(chatID, htmlf("✅ <code>%d</code> artık sadece şu verileri görür: %s", targetChatID, scope.describe()))
+	msg.ParseMode = "HTML"
+	bot.Send(msg)
+}
+
+// CampaignMilestone kampanya toplamı için duyurulacak hedefi tutar.
+// Every > 0 ise her Every tutarında bir (100k, 200k, ...), değilse tek seferlik Target tutarında duyurulur.
+type CampaignMilestone struct {
+	bun.BaseModel `bun:"table:campaign_milestones,alias:cm"`
+
+	ID            int64     `bun:"id,pk,autoincrement"`
+	Campaign      string    `bun:"campaign,notnull"`
+	Every         float64   `bun:"every,notnull,default:0"`
+	Target        float64   `bun:"target,notnull,default:0"`
+	ChatIDs       []int64   `bun:"chat_ids,array"`
+	LastAnnounced float64   `bun:"last_announced,notnull,default:0"`
+	AnnouncedAt   time.Time `bun:"announced_at,nullzero"`
+	CreatedBy     int64     `bun:"created_by"`
+	CreatedAt     time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// reached kampanya toplamına göre henüz duyurulmamış en yüksek eşiği döner (yoksa 0)
+func (m CampaignMilestone) reached(total float64) float64 {
+	var threshold float64
+	if m.Every > 0 {
+		threshold = math.Floor(total/m.Every) * m.Every
+	} else if total >= m.Target {
+		threshold = m.Target
+	}
+	if threshold <= m.LastAnnounced {
+		return 0
+	}
+	return threshold
+}
+
+// describe hedef kuralını okunabilir metne çevirir
+func (m CampaignMilestone) describe() string {
+	if m.Every > 0 {
+		return "her " + formatMoney(m.Every, "TRY")
+	}
+	return formatMoney(m.Target, "TRY")
+}
+
+// Hedef tanımları ve kampanya toplamları bu süre boyunca bellekten okunur; süre dolunca
+// veritabanından yeniden yüklenir (birden fazla API örneği çalışırken toplamlar da böylece eşitlenir)
+const milestoneCacheTTL = time.Minute
+
+var milestones []CampaignMilestone
+var milestoneTotals map[string]float64
+var milestonesMaxID int64 // Toplamlara dahil edilen en yüksek sipariş ID'si
+var milestonesLoadedAt time.Time
+var milestonesMutex sync.Mutex
+
+// Hedefler TRY cinsindendir; diğer para birimlerindeki bağışlar toplama katılmaz
+const milestoneCurrency = "TRY"
+
+// loadMilestones hedefleri ve hedefi olan kampanyaların güncel toplamlarını okur (kilit almaz).
+// Toplamlar dönen maxID'ye kadarki siparişleri içerir; sonrakiler bellekte eklenir.
+func loadMilestones(ctx context.Context) ([]CampaignMilestone, map[string]float64, int64, error) {
+	var defs []CampaignMilestone
+	if err := db.NewSelect().Model(&defs).OrderExpr("campaign, id").Scan(ctx); err != nil {
+		return nil, nil, 0, err
+	}
+
+	var maxID int64
+	if err := db.NewSelect().TableExpr("orders").ColumnExpr("COALESCE(MAX(id), 0)").Scan(ctx, &maxID); err != nil {
+		return nil, nil, 0, err
+	}
+
+	totals := make(map[string]float64)
+	if len(defs) > 0 {
+		campaigns := make([]string, 0, len(defs))
+		for _, m := range defs {
+			campaigns = append(campaigns, m.Campaign)
+		}
+		var rows []struct {
+			UTMCampaign string  `bun:"utm_campaign"`
+			Total       float64 `bun:"total"`
+		}
+		err := db.NewSelect().
+			TableExpr("orders AS o").
+			Join("JOIN utm_campaigns AS c ON c.id = o.utm_campaign_id").
+			ColumnExpr("c.name AS utm_campaign").
+			ColumnExpr("SUM(o.amount) as total").
+			Where("NOT o.is_test").
+			Where("UPPER(o.currency) = ?", milestoneCurrency).
+			Where("o.id <= ?", maxID).
+			Where("c.name IN (?)", bun.In(campaigns)).
+			GroupExpr("c.name").
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for _, r := range rows {
+			totals[r.UTMCampaign] = r.Total
+		}
+	}
+	return defs, totals, maxID, nil
+}
+
+// invalidateMilestones hedef önbelleğini temizler (tanım değişikliğinden sonra)
+func invalidateMilestones() {
+	milestonesMutex.Lock()
+	milestonesLoadedAt = time.Time{}
+	milestonesMutex.Unlock()
+}
+
+// checkCampaignMilestones kaydedilen siparişi kampanyanın önbellekteki toplamına ekler ve aşılan
+// hedefleri duyurur. Koşullu UPDATE sayesinde aynı eşik birden fazla örnekten iki kez duyurulmaz.
+// Veritabanı işlemleri kilit dışında yapılır, kilit sadece bellekteki durumu korur.
+func checkCampaignMilestones(ctx context.Context, order *Order) {
+	if order.IsTest || order.UTMCampaign == "" || !strings.EqualFold(order.Currency, milestoneCurrency) || globalBot == nil {
+		return
+	}
+
+	milestonesMutex.Lock()
+	stale := time.Since(milestonesLoadedAt) > milestoneCacheTTL
+	milestonesMutex.Unlock()
+	if stale {
+		defs, totals, maxID, err := loadMilestones(ctx)
+		if err != nil {
+			log.Printf("Kampanya hedefleri yüklenemedi: %v", err)
+			return
+		}
+		milestonesMutex.Lock()
+		// Eşzamanlı iki yüklemeden eski olanı yenisinin üzerine yazılmaz
+		if maxID >= milestonesMaxID {
+			milestones = defs
+			milestoneTotals = totals
+			milestonesMaxID = maxID
+			milestonesLoadedAt = time.Now()
+		}
+		milestonesMutex.Unlock()
+	}
+
+	milestonesMutex.Lock()
+	// Yüklenen toplam bu ID'ye kadarki siparişleri zaten içerir, aynı sipariş iki kez sayılmaz
+	if order.ID > milestonesMaxID {
+		milestoneTotals[order.UTMCampaign] += order.Amount
+	}
+	total := milestoneTotals[order.UTMCampaign]
+	var due []CampaignMilestone
+	for i, m := range milestones {
+		if m.Campaign != order.UTMCampaign {
+			continue
+		}
+		threshold := m.reached(total)
+		if threshold == 0 {
+			continue
+		}
+		// Bellekte hemen işaretlenir, aynı eşik bu örnekte tekrar denenmez
+		milestones[i].LastAnnounced = threshold
+		m.LastAnnounced = threshold
+		due = append(due, m)
+	}
+	milestonesMutex.Unlock()
+
+	for _, m := range due {
+		res, err := db.NewUpdate().
+			Model((*CampaignMilestone)(nil)).
+			Set("last_announced = ?", m.LastAnnounced).
+			Set("announced_at = ?", time.Now()).
+			Where("id = ?", m.ID).
+			Where("last_announced < ?", m.LastAnnounced).
+			Exec(ctx)
+		if err != nil {
+			log.Printf("Kampanya hedefi güncelleme hatası (id=%d): %v", m.ID, err)
+			continue
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			// Başka bir örnek bu eşiği zaten duyurdu
+			continue
+		}
+		announceMilestone(ctx, order, m, total)
+	}
+}
+
+// announceMilestone hedef duyurusunu seçili sohbetlere (yoksa bildirim hedeflerine) gönderir
+func announceMilestone(ctx context.Context, order *Order, m CampaignMilestone, total float64) {
+	chatIDs := m.ChatIDs
+	if len(chatIDs) == 0 {
+		chatIDs = getNotificationChatIDs()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎉 <b>KAMPANYA HEDEFİ AŞILDI!</b> 🎉\n\n")
+	sb.WriteString(htmlf("🎯 <b>%s</b> kampanyası <b>%s</b> bağışa ulaştı!\n\n", m.Campaign, formatMoney(m.LastAnnounced, "TRY")))
+	sb.WriteString(htmlf("💰 Güncel toplam: %s\n", formatMoney(total, "TRY")))
+	if m.Every > 0 {
+		sb.WriteString(htmlf("⏭️ Sıradaki hedef: %s\n", formatMoney(m.LastAnnounced+m.Every, "TRY")))
+	}
+	message := sb.String()
+
+	for _, chatID := range chatIDs {
+		// Kapsamlı sohbetler sadece kendi kampanyalarının duyurularını alır
+		if !getChatScope(ctx, chatID).matches(order) {
+			continue
+		}
+		msg := tgbotapi.NewMessage(chatID, message)
+		msg.ParseMode = "HTML"
+		if _, err := globalBot.Send(msg); err != nil {
+			log.Printf("Hedef duyurusu gönderilemedi (chat_id=%d): %v", chatID, err)
+		}
+	}
+	log.Printf("Kampanya hedefi duyuruldu: kampanya=%s, eşik=%.2f, toplam=%.2f", m.Campaign, m.LastAnnounced, total)
+}
+
+// parseMilestoneAmount hedef tutarını okur: 100000, 100k, 1.5m
+func parseMilestoneAmount(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	multiplier := 1.0
+	if rest, ok := strings.CutSuffix(value, "k"); ok {
+		value, multiplier = rest, 1_000
+	} else if rest, ok := strings.CutSuffix(value, "m"); ok {
+		value, multiplier = rest, 1_000_000
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("geçersiz tutar")
+	}
+	return amount * multiplier, nil
+}
+
+// handleHedefCommand /hedef komutunu işler - Kampanya gelir hedeflerini listeler/tanımlar/siler
+func handleHedefCommand(bot *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	// Hedefler tüm kampanyaları kapsar, kapsamlı sohbetlere sızmaması için listeleme de yöneticilere açık
+	if !isAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "⛔ Kampanya hedeflerini sadece yöneticiler yönetebilir.")
+		bot.Send(msg)
+		return
+	}
+
+	ctx := context.Background()
+	fields := strings.Fields(args)
+
+	usage := "⚠️ Kullanım:\n/hedef — Listele\n/hedef ekle <kampanya> her=100k [sohbet=-100123,-100456]\n/hedef ekle <kampanya> hedef=250k,500k,1m [sohbet=...]\n/hedef sil <id>"
+
+	if len(fields) == 0 {
+		var defs []CampaignMilestone
+		if err := db.NewSelect().Model(&defs).OrderExpr("campaign, id").Scan(ctx); err != nil {
+			log.Printf("Kampanya hedefi listeleme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+
+		var sb strings.Builder
+		sb.WriteString("🎯 <b>Kampanya Hedefleri</b>\n\n")
+		if len(defs) == 0 {
+			sb.WriteString("ℹ️ Henüz hedef tanımlanmamış.\n\n")
+		}
+		for _, m := range defs {
+			sb.WriteString(htmlf("<code>#%d</code> <b>%s</b> — %s\n", m.ID, m.Campaign, m.describe()))
+			if m.LastAnnounced > 0 {
+				sb.WriteString(htmlf("   Son duyuru: %s\n", formatMoney(m.LastAnnounced, "TRY")))
+			}
+			if len(m.ChatIDs) > 0 {
+				sb.WriteString(htmlf("   Sohbetler: %s\n", fmt.Sprint(m.ChatIDs)))
+			}
+		}
+		sb.WriteString("\n<i>Sohbet belirtilmezse duyurular bildirim hedeflerine gönderilir.</i>")
+
+		msg := tgbotapi.NewMessage(chatID, sb.String())
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+		return
+	}
+
+	switch fields[0] {
+	case "sil":
+		if len(fields) != 2 {
+			bot.Send(tgbotapi.NewMessage(chatID, usage))
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "#"), 10, 64)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, usage))
+			return
+		}
+		res, err := db.NewDelete().Model((*CampaignMilestone)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			log.Printf("Kampanya hedefi silme hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ #%d numaralı hedef bulunamadı.", id)))
+			return
+		}
+		invalidateMilestones()
+		log.Printf("Kampanya hedefi silindi: id=%d, user=%d", id, userID)
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑️ #%d numaralı hedef silindi.", id)))
+
+	case "ekle":
+		if len(fields) < 3 {
+			bot.Send(tgbotapi.NewMessage(chatID, usage))
+			return
+		}
+		campaign := fields[1]
+
+		var every float64
+		var targets []float64
+		var chatIDs []int64
+		for _, field := range fields[2:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || value == "" {
+				bot.Send(tgbotapi.NewMessage(chatID, usage))
+				return
+			}
+			switch strings.ToLower(key) {
+			case "her":
+				amount, err := parseMilestoneAmount(value)
+				if err != nil {
+					bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Geçersiz tutar: %s", value)))
+					return
+				}
+				every = amount
+			case "hedef":
+				for _, part := range strings.Split(value, ",") {
+					amount, err := parseMilestoneAmount(part)
+					if err != nil {
+						bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Geçersiz tutar: %s", part)))
+						return
+					}
+					targets = append(targets, amount)
+				}
+			case "sohbet":
+				for _, part := range strings.Split(value, ",") {
+					id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+					if err != nil {
+						bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Geçersiz chat ID: %s", part)))
+						return
+					}
+					chatIDs = append(chatIDs, id)
+				}
+			default:
+				bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Bilinmeyen alan: %s (her, hedef, sohbet)", key)))
+				return
+			}
+		}
+		if (every > 0) == (len(targets) > 0) {
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ her=<tutar> veya hedef=<tutar,...> alanlarından biri gerekli.\n\n"+usage))
+			return
+		}
+
+		// Mevcut toplam başlangıç kabul edilir; tanımdan önce aşılmış eşikler geriye dönük duyurulmaz
+		var current float64
+		err := db.NewSelect().
+			TableExpr("orders AS o").
+			Join("JOIN utm_campaigns AS c ON c.id = o.utm_campaign_id").
+			ColumnExpr("COALESCE(SUM(o.amount), 0)").
+			Where("NOT o.is_test").
+			Where("UPPER(o.currency) = ?", milestoneCurrency).
+			Where("c.name = ?", campaign).
+			Scan(ctx, &current)
+		if err != nil {
+			log.Printf("Kampanya toplamı sorgu hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+
+		var defs []CampaignMilestone
+		if every > 0 {
+			defs = append(defs, CampaignMilestone{Campaign: campaign, Every: every})
+		}
+		for _, target := range targets {
+			defs = append(defs, CampaignMilestone{Campaign: campaign, Target: target})
+		}
+		for i := range defs {
+			defs[i].ChatIDs = chatIDs
+			defs[i].CreatedBy = userID
+			defs[i].LastAnnounced = defs[i].reached(current)
+		}
+
+		if _, err := db.NewInsert().Model(&defs).Exec(ctx); err != nil {
+			log.Printf("Kampanya hedefi kayıt hatası: %v", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Veritabanı sorgu hatası oluştu.")
+			bot.Send(msg)
+			return
+		}
+		invalidateMilestones()
+		log.Printf("Kampanya hedefi tanımlandı: kampanya=%s, her=%.2f, hedefler=%v, user=%d", campaign, every, targets, userID)
+
+		var sb strings.Builder
+		sb.WriteString(htmlf("✅ <b>%s</b> için %d hedef tanımlandı.\n\n", campaign, len(defs)))
+		sb.WriteString(htmlf("💰 Mevcut toplam: %s\n", formatMoney(current, "TRY")))
+		for _, m := range defs {
+			if m.Every > 0 {
+				sb.WriteString(htmlf("• %s — ilk duyuru %s\n", m.describe(), formatMoney(m.LastAnnounced+m.Every, "TRY")))
+			} else if m.LastAnnounced > 0 {
+				sb.WriteString(htmlf("• %s — zaten aşılmış, duyurulmayacak\n", m.describe()))
+			} else {
+				sb.WriteString(htmlf("• %s\n", m.describe()))
+			}
+		}
+
+		msg := tgbotapi.NewMessage(chatID, sb.String())
+		msg.ParseMode = "HTML"
+		bot.Send(msg)
+
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+	}
+}
+
+// ingestConfirmation callback_url'e gönderilen onay gövdesi
+type ingestConfirmation struct {
+	OrderID           string    `json:"order_id"`
+	Stored            bool      `json:"stored"`
+	IsTest            bool      `json:"is_test"`
+	NotificationsSent int       `json:"notifications_sent"`
+	NotificationsFail int       `json:"notifications_failed"`
+	ConfirmedAt       time.Time `json:"confirmed_at"`
+}
+
+// Callback denemeleri arasında beklenen süreler (ilk deneme hemen yapılır)
+var callbackRetryDelays = []time.Duration{2 * time.Second, 10 * time.Second}
+
+var callbackClient = &http.Client{
+	Timeout: 10 * time.Second,
+	// Yönlendirmeler izin listesini atlatmak için kullanılabilir, takip edilmez
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// getCallbackAllowedHosts callback gönderilebilecek host'ları döner (varsayılan: site alan adları)
+func getCallbackAllowedHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(getEnv("CALLBACK_ALLOWED_HOSTS", "hayratyardim.org,www.hayratyardim.org"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// validateCallbackURL callback adresinin izin verilen bir host'a HTTPS ile gittiğini doğrular (SSRF koruması)
+func validateCallbackURL(raw string) error {
+	if os.Getenv("CALLBACK_SIGNING_SECRET") == "" {
+		return fmt.Errorf("callback desteği yapılandırılmamış")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("geçersiz callback_url")
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("callback_url https olmalı")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range getCallbackAllowedHosts() {
+		if host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("callback_url host'u izin listesinde değil: %s", host)
+}
+
+// signCallback gövdeyi zaman damgasıyla birlikte HMAC-SHA256 ile imzalar
+// İmza: hex(HMAC(secret, "<timestamp>.<body>")) - alıcı aynı hesaplamayla doğrular, eski zaman damgalarını reddeder
+func signCallback(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendIngestCallback onayı imzalı olarak callback adresine POST eder, başarısızsa birkaç kez tekrar dener
+func sendIngestCallback(callbackURL string, confirmation ingestConfirmation) {
+	body, err := json.Marshal(confirmation)
+	if err != nil {
+		log.Printf("Callback gövdesi oluşturulamadı (order_id=%s): %v", confirmation.OrderID, err)
+		return
+	}
+	secret := os.Getenv("CALLBACK_SIGNING_SECRET")
+
+	for attempt := 0; attempt <= len(callbackRetryDelays); attempt++ {
+		if attempt > 0 {
+			time.Sleep(callbackRetryDelays[attempt-1])
+		}
+
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Callback isteği oluşturulamadı (order_id=%s): %v", confirmation.OrderID, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-UTM-Timestamp", timestamp)
+		req.Header.Set("X-UTM-Signature", "sha256="+signCallback(secret, timestamp, body))
+
+		resp, err := callbackClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				log.Printf("Callback gönderildi: order_id=%s", confirmation.OrderID)
+				return
+			}
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		log.Printf("Callback hatası (order_id=%s, deneme %d): %v", confirmation.OrderID, attempt+1, err)
+	}
+	log.Printf("Callback gönderilemedi, denemeler tükendi: order_id=%s", confirmation.OrderID)
+}